@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Flag is an option of a command, e.g. "-n" or "--since 2019-01-01".
+type Flag struct {
+	Name string
+	// Value is the placeholder of its value in help ; empty if the flag takes no value.
+	Value string
+	Help  string
+}
+
+// Invocation is a parsed command entry.
+type Invocation struct {
+	Args  []string
+	Flags map[string]string
+}
+
+// Has returns whether the flag was given.
+func (i Invocation) Has(name string) bool {
+	_, ok := i.Flags[name]
+	return ok
+}
+
+// Get returns the value of the flag or empty.
+func (i Invocation) Get(name string) string {
+	return i.Flags[name]
+}
+
+// token is a word of an entry with the offset in the entry where it starts.
+type token struct {
+	text   string
+	offset int
+}
+
+var errUnterminatedQuote = errors.New("unterminated quote")
+
+// tokenize splits the entry into words like a shell does: whitespace separates words,
+// single quotes take everything literally, double quotes allow backslash escapes of \ and ",
+// and outside quotes a backslash escapes any character.
+// If the entry ends inside a quote then the last token is returned together with errUnterminatedQuote.
+func tokenize(entry string) (list []token, err error) {
+	var b strings.Builder
+	inWord := false
+	start := 0
+	var quote rune
+	escaped := false
+	for i, r := range entry {
+		if escaped {
+			b.WriteRune(r)
+			escaped = false
+			continue
+		}
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				b.WriteRune(r)
+			}
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				if i+1 < len(entry) && (entry[i+1] == '"' || entry[i+1] == '\\') {
+					escaped = true
+				} else {
+					b.WriteRune(r)
+				}
+			default:
+				b.WriteRune(r)
+			}
+		case r == ' ' || r == '\t':
+			if inWord {
+				list = append(list, token{text: b.String(), offset: start})
+				b.Reset()
+				inWord = false
+			}
+		default:
+			if !inWord {
+				inWord = true
+				start = i
+			}
+			switch r {
+			case '\'', '"':
+				quote = r
+			case '\\':
+				escaped = true
+			default:
+				b.WriteRune(r)
+			}
+		}
+	}
+	if inWord {
+		list = append(list, token{text: b.String(), offset: start})
+	}
+	if quote != 0 {
+		err = errUnterminatedQuote
+	}
+	return
+}
+
+// parseInvocation separates the flags of the command from its arguments.
+// Flags are recognized until "--" ; a flag with a value takes the next word.
+func parseInvocation(words []string, flags []Flag) (Invocation, error) {
+	inv := Invocation{Flags: map[string]string{}}
+	noMoreFlags := false
+	for i := 0; i < len(words); i++ {
+		each := words[i]
+		if noMoreFlags || len(each) < 2 || !strings.HasPrefix(each, "-") {
+			inv.Args = append(inv.Args, each)
+			continue
+		}
+		if each == "--" {
+			noMoreFlags = true
+			continue
+		}
+		name, value, hasValue := strings.Cut(each, "=")
+		flag, ok := findFlag(flags, name)
+		if !ok {
+			return inv, fmt.Errorf("unknown flag %s", name)
+		}
+		if flag.Value == "" {
+			inv.Flags[flag.Name] = ""
+			continue
+		}
+		if !hasValue {
+			if i+1 == len(words) {
+				return inv, fmt.Errorf("flag %s needs a value", name)
+			}
+			i++
+			value = words[i]
+		}
+		inv.Flags[flag.Name] = value
+	}
+	return inv, nil
+}
+
+// findFlag returns the flag by name ; "--since" is the same flag as "-since".
+func findFlag(flags []Flag, name string) (Flag, bool) {
+	for _, each := range flags {
+		if strings.TrimLeft(each.Name, "-") == strings.TrimLeft(name, "-") {
+			return each, true
+		}
+	}
+	return Flag{}, false
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	for _, each := range []struct {
+		entry string
+		want  []string
+	}{
+		{"", nil},
+		{"ls", []string{"ls"}},
+		{"  cp   a.jpg\tb.jpg ", []string{"cp", "a.jpg", "b.jpg"}},
+		{`cp "My Trip.jpg"`, []string{"cp", "My Trip.jpg"}},
+		{`cp 'My "Trip".jpg'`, []string{"cp", `My "Trip".jpg`}},
+		{`cp My\ Trip.jpg`, []string{"cp", "My Trip.jpg"}},
+		{`cp "a \"b\" \\ \c"`, []string{"cp", `a "b" \ \c`}},
+		{`cp 'a\b'`, []string{"cp", `a\b`}},
+		{`cd "/Shared drives"/Family`, []string{"cd", "/Shared drives/Family"}},
+		{`cp ""`, []string{"cp", ""}},
+	} {
+		tokens, err := tokenize(each.entry)
+		if err != nil {
+			t.Errorf("%q: %v", each.entry, err)
+			continue
+		}
+		var got []string
+		for _, tok := range tokens {
+			got = append(got, tok.text)
+		}
+		if !reflect.DeepEqual(got, each.want) {
+			t.Errorf("%q: got %q want %q", each.entry, got, each.want)
+		}
+	}
+}
+
+func TestTokenizeOffsetsAndUnterminatedQuote(t *testing.T) {
+	tokens, err := tokenize(`cp "My Tr`)
+	if err != errUnterminatedQuote {
+		t.Errorf("got %v", err)
+	}
+	if len(tokens) != 2 || tokens[1].text != "My Tr" || tokens[1].offset != 3 {
+		t.Errorf("got %+v", tokens)
+	}
+}
+
+func TestParseInvocation(t *testing.T) {
+	flags := []Flag{{Name: "-n"}, {Name: "-since", Value: "date"}}
+	in, err := parseInvocation([]string{"--n", "-since", "2019-01-01", "a", "--", "-b", "-"}, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !in.Has("-n") || in.Get("-since") != "2019-01-01" {
+		t.Errorf("flags: got %v", in.Flags)
+	}
+	if !reflect.DeepEqual(in.Args, []string{"a", "-b", "-"}) {
+		t.Errorf("args: got %q", in.Args)
+	}
+	if _, err := parseInvocation([]string{"-since"}, flags); err == nil {
+		t.Error("expected error for missing value")
+	}
+	if _, err := parseInvocation([]string{"-x"}, flags); err == nil {
+		t.Error("expected error for unknown flag")
+	}
+}
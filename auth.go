@@ -2,30 +2,33 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"net/url"
 	"os/exec"
+	"strings"
 	"time"
 
 	"golang.org/x/oauth2"
 )
 
-// Retrieve a token, saves the token, then returns the generated client.
-func getClient(config *oauth2.Config) *http.Client {
-	// The file token.json stores the user's access and refresh tokens, and is
-	// created automatically when the authorization flow completes for the first
-	// time.
-	tokFile := "token.json"
-	tok, err := tokenFromFile(tokFile)
-	if err != nil {
-		tok = getTokenFromWeb(context.Background(), config)
-		saveToken(tokFile, tok)
+// There is no device code flow: Google allows no Photos scopes and only drive.file for Drive in it.
+// https://developers.google.com/identity/protocols/oauth2/limited-input-device#allowedscopes
+const (
+	AuthMode_Browser = "browser"
+	AuthMode_Manual  = "manual"
+)
+
+// getToken runs the authorization flow that matches the mode.
+func getToken(ctx context.Context, config *oauth2.Config, authMode string) *oauth2.Token {
+	switch authMode {
+	case AuthMode_Manual:
+		return getTokenFromPaste(ctx, config)
+	default:
+		return getTokenFromWeb(ctx, config)
 	}
-	return config.Client(context.Background(), tok)
 }
 
 func getTokenFromWeb(ctx context.Context, config *oauth2.Config) *oauth2.Token {
@@ -77,43 +80,56 @@ func openURL(url string) {
 	log.Printf("Error opening URL in browser.")
 }
 
-// Request a token from the web, then returns the retrieved token.
-func getTokenFromWebBROKEN(config *oauth2.Config) *oauth2.Token {
-	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	fmt.Printf("Go to the following link in your browser then type the "+
-		"authorization code: \n%v\n", authURL)
+// Request a token by letting the user paste the code (or the full redirected URL)
+// from a browser on another machine.
+func getTokenFromPaste(ctx context.Context, config *oauth2.Config) *oauth2.Token {
+	// the browser will fail to load this page ; its address bar still holds the code
+	config.RedirectURL = "http://localhost"
+	randState := fmt.Sprintf("st%d", time.Now().UnixNano())
+	authURL := config.AuthCodeURL(randState, oauth2.AccessTypeOffline)
+	fmt.Printf("Go to the following link in your browser, authorize and then paste "+
+		"the code (or the complete address of the page you are redirected to): \n%v\n", authURL)
 
-	var authCode string
-	if _, err := fmt.Scan(&authCode); err != nil {
+	var pasted string
+	if _, err := fmt.Scanln(&pasted); err != nil {
 		log.Fatalf("Unable to read authorization code %v", err)
 	}
-
-	tok, err := config.Exchange(context.TODO(), authCode)
+	code, err := codeFromPaste(pasted, randState)
+	if err != nil {
+		log.Fatalf("Unable to read authorization code %v", err)
+	}
+	tok, err := config.Exchange(ctx, code)
 	if err != nil {
 		log.Fatalf("Unable to retrieve token from web %v", err)
 	}
 	return tok
 }
 
-// Retrieves a token from a local file.
-func tokenFromFile(file string) (*oauth2.Token, error) {
-	f, err := os.Open(file)
+// codeFromPaste accepts either a bare code or a redirect URL with code and state parameters.
+func codeFromPaste(pasted, state string) (string, error) {
+	pasted = strings.TrimSpace(pasted)
+	if !strings.Contains(pasted, "://") {
+		return pasted, nil
+	}
+	u, err := url.Parse(pasted)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	defer f.Close()
-	tok := &oauth2.Token{}
-	err = json.NewDecoder(f).Decode(tok)
-	return tok, err
+	if u.Query().Get("state") != state {
+		return "", fmt.Errorf("state does not match")
+	}
+	code := u.Query().Get("code")
+	if code == "" {
+		return "", fmt.Errorf("no code in %s", pasted)
+	}
+	return code, nil
 }
 
-// Saves a token to a file path.
-func saveToken(path string, token *oauth2.Token) {
-	fmt.Printf("Saving credential file to: %s\n", path)
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		log.Fatalf("Unable to cache oauth token: %v", err)
+// Saves a token to the store.
+func saveToken(store TokenStore, token *oauth2.Token) error {
+	fmt.Printf("Saving credential to: %s\n", store)
+	if err := store.Save(token); err != nil {
+		return fmt.Errorf("unable to cache oauth token: %v", err)
 	}
-	defer f.Close()
-	json.NewEncoder(f).Encode(token)
+	return nil
 }
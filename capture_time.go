@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// captureTime returns the time to put on the Photos timeline for a file without EXIF date,
+// taken from the filename or else the Drive modification time.
+func captureTime(file *drive.File) (time.Time, bool) {
+	if t, ok := filenameDates.Parse(file.Name); ok {
+		return t, true
+	}
+	t, err := time.Parse(time.RFC3339, file.ModifiedTime)
+	return t, err == nil
+}
+
+// takenTime returns when the media was taken according to its EXIF data (as extracted by Drive)
+// or else the date in its filename.
+func takenTime(file *drive.File) (time.Time, bool) {
+	if meta := file.ImageMediaMetadata; meta != nil && meta.Time != "" {
+		if t, err := time.ParseInLocation("2006:01:02 15:04:05", meta.Time, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return filenameDates.Parse(file.Name)
+}
+
+// setCaptureTime returns a copy of the content with the capture time written into it,
+// if the format is supported and the content has no capture time yet.
+func setCaptureTime(content []byte, mimeType string, t time.Time) ([]byte, bool) {
+	switch {
+	case mimeType == "image/jpeg":
+		return jpegSetDateTimeOriginal(content, t)
+	case mimeType == "video/quicktime" || mimeType == "video/mp4":
+		return quicktimeSetCreationTime(content, t)
+	}
+	return content, false
+}
+
+// jpegSetDateTimeOriginal inserts an EXIF segment with DateTime, DateTimeOriginal and DateTimeDigitized
+// into a JPEG that has no EXIF segment.
+// https://www.cipa.jp/std/documents/e/DC-X008-Translation-2019-E.pdf
+func jpegSetDateTimeOriginal(data []byte, t time.Time) ([]byte, bool) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return data, false
+	}
+	insertAt := 2
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xFF {
+			return data, false
+		}
+		marker := data[pos+1]
+		if marker == 0xDA { // start of scan, no more metadata
+			break
+		}
+		size := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if size < 2 || pos+2+size > len(data) { // truncated or corrupt
+			return data, false
+		}
+		if marker == 0xE1 && bytes.HasPrefix(data[pos+4:], []byte("Exif\x00\x00")) {
+			return data, false
+		}
+		if marker == 0xE0 { // keep JFIF first
+			insertAt = pos + 2 + size
+		}
+		pos += 2 + size
+	}
+	segment := exifSegment(t)
+	result := make([]byte, 0, len(data)+len(segment))
+	result = append(result, data[:insertAt]...)
+	result = append(result, segment...)
+	result = append(result, data[insertAt:]...)
+	return result, true
+}
+
+// exifSegment returns an APP1 segment with a big endian TIFF structure holding the dates.
+func exifSegment(t time.Time) []byte {
+	const (
+		ifd0Offset    = 8
+		exifIFDOffset = ifd0Offset + 2 + 2*12 + 4
+		valueOffset   = exifIFDOffset + 2 + 2*12 + 4
+		typeASCII     = 2
+		typeLong      = 4
+	)
+	// EXIF dates have no zone and are read as local time
+	value := []byte(t.Local().Format("2006:01:02 15:04:05") + "\x00")
+	tiff := new(bytes.Buffer)
+	be := binary.BigEndian
+	tiff.WriteString("MM")
+	binary.Write(tiff, be, uint16(42))
+	binary.Write(tiff, be, uint32(ifd0Offset))
+	entry := func(tag, kind uint16, count, valueOrOffset uint32) {
+		binary.Write(tiff, be, tag)
+		binary.Write(tiff, be, kind)
+		binary.Write(tiff, be, count)
+		binary.Write(tiff, be, valueOrOffset)
+	}
+	// IFD0
+	binary.Write(tiff, be, uint16(2))
+	entry(0x0132, typeASCII, uint32(len(value)), valueOffset) // DateTime
+	entry(0x8769, typeLong, 1, exifIFDOffset)                 // Exif IFD pointer
+	binary.Write(tiff, be, uint32(0))
+	// Exif IFD
+	binary.Write(tiff, be, uint16(2))
+	entry(0x9003, typeASCII, uint32(len(value)), valueOffset) // DateTimeOriginal
+	entry(0x9004, typeASCII, uint32(len(value)), valueOffset) // DateTimeDigitized
+	binary.Write(tiff, be, uint32(0))
+	tiff.Write(value)
+
+	segment := new(bytes.Buffer)
+	segment.Write([]byte{0xFF, 0xE1})
+	binary.Write(segment, be, uint16(2+6+tiff.Len()))
+	segment.WriteString("Exif\x00\x00")
+	segment.Write(tiff.Bytes())
+	return segment.Bytes()
+}
+
+// quicktimeSetCreationTime sets the creation and modification time in the movie header (mvhd)
+// of a QuickTime or MP4 file if it is not set.
+// https://developer.apple.com/documentation/quicktime-file-format/movie_header_atom
+func quicktimeSetCreationTime(data []byte, t time.Time) ([]byte, bool) {
+	moovStart, moovEnd, ok := findBox(data, "moov")
+	if !ok {
+		return data, false
+	}
+	mvhdStart, mvhdEnd, ok := findBox(data[moovStart:moovEnd], "mvhd")
+	if !ok || mvhdEnd-mvhdStart < 4+16 {
+		return data, false
+	}
+	// seconds since midnight, January 1, 1904 UTC
+	since1904 := t.Unix() + 2082844800
+	result := append([]byte{}, data...)
+	header := result[moovStart+mvhdStart : moovStart+mvhdEnd]
+	be := binary.BigEndian
+	if header[0] == 1 { // version 1 has 64 bit times
+		if be.Uint64(header[4:]) != 0 {
+			return data, false
+		}
+		be.PutUint64(header[4:], uint64(since1904))
+		be.PutUint64(header[12:], uint64(since1904))
+		return result, true
+	}
+	if be.Uint32(header[4:]) != 0 {
+		return data, false
+	}
+	be.PutUint32(header[4:], uint32(since1904))
+	be.PutUint32(header[8:], uint32(since1904))
+	return result, true
+}
+
+// findBox returns the start and end of the content of the first box (atom) with the type.
+func findBox(data []byte, kind string) (start, end int, ok bool) {
+	for pos := 0; pos+8 <= len(data); {
+		size := int(binary.BigEndian.Uint32(data[pos:]))
+		header := 8
+		if size == 1 && pos+16 <= len(data) {
+			size = int(binary.BigEndian.Uint64(data[pos+8:]))
+			header = 16
+		} else if size == 0 {
+			size = len(data) - pos
+		}
+		if size < header || pos+size > len(data) {
+			return 0, 0, false
+		}
+		if string(data[pos+4:pos+8]) == kind {
+			return pos + header, pos + size, true
+		}
+		pos += size
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+	"time"
+)
+
+var captured = time.Date(2017, 8, 12, 14, 35, 1, 0, time.UTC)
+
+func TestJPEGSetDateTimeOriginal(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	fixed, ok := setCaptureTime(buf.Bytes(), "image/jpeg", captured)
+	if !ok {
+		t.Fatal("expected the date to be written")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(fixed)); err != nil {
+		t.Fatalf("no longer a JPEG: %v", err)
+	}
+	dates := readEXIFDates(t, fixed)
+	for _, tag := range []uint16{0x0132, 0x9003, 0x9004} {
+		if got, want := dates[tag], captured.Local().Format("2006:01:02 15:04:05"); got != want {
+			t.Errorf("tag %#x: got %q", tag, got)
+		}
+	}
+	if _, ok := setCaptureTime(fixed, "image/jpeg", captured); ok {
+		t.Error("a JPEG with EXIF must not be changed")
+	}
+}
+
+func TestJPEGSetDateTimeOriginalTruncated(t *testing.T) {
+	for _, each := range [][]byte{
+		{0xFF, 0xD8, 0xFF, 0xE0, 0x10, 0x00, 0x01, 0x02}, // APP0 longer than the data
+		{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x00, 0x01, 0x02}, // APP0 with an invalid size
+	} {
+		if _, ok := jpegSetDateTimeOriginal(each, captured); ok {
+			t.Errorf("% X: expected no change", each)
+		}
+	}
+}
+
+func TestEXIFDateIsLocalTime(t *testing.T) {
+	defer func(local *time.Location) { time.Local = local }(time.Local)
+	time.Local = time.FixedZone("UTC+2", 2*60*60)
+	buf := new(bytes.Buffer)
+	jpeg.Encode(buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil)
+	fixed, _ := jpegSetDateTimeOriginal(buf.Bytes(), time.Date(2017, 8, 12, 23, 0, 0, 0, time.UTC))
+	if got := readEXIFDates(t, fixed)[0x9003]; got != "2017:08:13 01:00:00" {
+		t.Errorf("got %q", got)
+	}
+}
+
+// readEXIFDates returns the ASCII values of the big endian IFD0 and Exif IFD by tag.
+func readEXIFDates(t *testing.T, data []byte) map[uint16]string {
+	t.Helper()
+	pos := 2
+	for data[pos+1] != 0xE1 {
+		pos += 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+	}
+	if string(data[pos+4:pos+10]) != "Exif\x00\x00" {
+		t.Fatal("no Exif header")
+	}
+	tiff := data[pos+10:]
+	be := binary.BigEndian
+	if string(tiff[:2]) != "MM" || be.Uint16(tiff[2:]) != 42 {
+		t.Fatal("no big endian TIFF header")
+	}
+	values := map[uint16]string{}
+	var readIFD func(offset uint32)
+	readIFD = func(offset uint32) {
+		count := int(be.Uint16(tiff[offset:]))
+		for i := 0; i < count; i++ {
+			entry := tiff[int(offset)+2+12*i:]
+			tag, kind, n, value := be.Uint16(entry), be.Uint16(entry[2:]), be.Uint32(entry[4:]), be.Uint32(entry[8:])
+			switch {
+			case tag == 0x8769:
+				readIFD(value)
+			case kind == 2:
+				values[tag] = string(bytes.TrimRight(tiff[value:value+n], "\x00"))
+			}
+		}
+	}
+	readIFD(be.Uint32(tiff[4:]))
+	return values
+}
+
+// box returns an ISO BMFF box ; a large size header is used if asked.
+func box(kind string, large bool, content ...[]byte) []byte {
+	body := bytes.Join(content, nil)
+	b := new(bytes.Buffer)
+	if large {
+		binary.Write(b, binary.BigEndian, uint32(1))
+		b.WriteString(kind)
+		binary.Write(b, binary.BigEndian, uint64(16+len(body)))
+	} else {
+		binary.Write(b, binary.BigEndian, uint32(8+len(body)))
+		b.WriteString(kind)
+	}
+	b.Write(body)
+	return b.Bytes()
+}
+
+// mvhd returns the content of a movie header of the version without times.
+func mvhd(version byte) []byte {
+	size := 100
+	if version == 1 {
+		size = 112
+	}
+	content := make([]byte, size)
+	content[0] = version
+	return content
+}
+
+func TestQuicktimeSetCreationTime(t *testing.T) {
+	since1904 := uint64(captured.Unix() + 2082844800)
+	for _, each := range []struct {
+		name    string
+		version byte
+		large   bool
+	}{
+		{"version 0", 0, false},
+		{"version 1", 1, false},
+		{"large mdat before moov", 0, true},
+	} {
+		mp4 := bytes.Join([][]byte{
+			box("ftyp", false, []byte("isom\x00\x00\x02\x00")),
+			box("mdat", each.large, make([]byte, 32)),
+			box("moov", false, box("mvhd", false, mvhd(each.version)), box("trak", false)),
+		}, nil)
+		fixed, ok := setCaptureTime(mp4, "video/mp4", captured)
+		if !ok {
+			t.Errorf("%s: expected the date to be written", each.name)
+			continue
+		}
+		if len(fixed) != len(mp4) {
+			t.Errorf("%s: size changed", each.name)
+		}
+		moovStart, moovEnd, _ := findBox(fixed, "moov")
+		start, _, ok := findBox(fixed[moovStart:moovEnd], "mvhd")
+		if !ok {
+			t.Fatalf("%s: no mvhd", each.name)
+		}
+		header := fixed[moovStart+start:]
+		be := binary.BigEndian
+		var created, modified uint64
+		if each.version == 1 {
+			created, modified = be.Uint64(header[4:]), be.Uint64(header[12:])
+		} else {
+			created, modified = uint64(be.Uint32(header[4:])), uint64(be.Uint32(header[8:]))
+		}
+		if created != since1904 || modified != since1904 {
+			t.Errorf("%s: got %d %d want %d", each.name, created, modified, since1904)
+		}
+		if _, ok := setCaptureTime(fixed, "video/mp4", captured); ok {
+			t.Errorf("%s: a movie with a creation time must not be changed", each.name)
+		}
+	}
+}
+
+func TestFindBoxRejectsTruncatedBox(t *testing.T) {
+	data := box("moov", false, make([]byte, 8))
+	if _, _, ok := findBox(data[:len(data)-1], "moov"); ok {
+		t.Error("expected a truncated box not to be found")
+	}
+}
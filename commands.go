@@ -0,0 +1,353 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Command is one REPL command.
+type Command struct {
+	Name    string
+	Aliases []string
+	// Args describes the arguments for help, e.g. "[name]" ; empty if none.
+	Args    string
+	MinArgs int
+	Flags   []Flag
+	// FlagsInHelp shows "[flags]" in the usage instead of each flag, e.g. when there are many.
+	FlagsInHelp bool
+	Help        string
+	// Complete returns candidates for the argument that starts with prefix ; can be nil.
+	Complete func(f *Finder, prefix string) []string
+	Run      func(f *Finder, in Invocation)
+}
+
+// Usage returns the name with its flags and argument description.
+func (c *Command) Usage() string {
+	b := new(strings.Builder)
+	b.WriteString(c.Name)
+	if c.FlagsInHelp && len(c.Flags) > 0 {
+		// see help of the command
+		b.WriteString(" [flags]")
+	} else {
+		for _, each := range c.Flags {
+			fmt.Fprintf(b, " [%s", each.Name)
+			if each.Value != "" {
+				fmt.Fprintf(b, " %s", each.Value)
+			}
+			b.WriteString("]")
+		}
+	}
+	if c.Args != "" {
+		fmt.Fprintf(b, " %s", c.Args)
+	}
+	return b.String()
+}
+
+// CommandRegistry holds the commands by name and alias.
+type CommandRegistry struct {
+	commands []*Command
+	byName   map[string]*Command
+}
+
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{byName: map[string]*Command{}}
+}
+
+func (r *CommandRegistry) Register(c *Command) {
+	r.commands = append(r.commands, c)
+	r.byName[c.Name] = c
+	for _, each := range c.Aliases {
+		r.byName[each] = c
+	}
+}
+
+// Lookup returns the command by name or alias, or nil.
+func (r *CommandRegistry) Lookup(name string) *Command {
+	return r.byName[name]
+}
+
+// Names returns the names of all commands in order of registration.
+func (r *CommandRegistry) Names() (list []string) {
+	for _, each := range r.commands {
+		list = append(list, each.Name)
+	}
+	return
+}
+
+// AllNames returns the names and aliases of all commands, sorted.
+func (r *CommandRegistry) AllNames() (list []string) {
+	for each := range r.byName {
+		list = append(list, each)
+	}
+	sort.Strings(list)
+	return
+}
+
+// Banner returns the one line summary of commands shown at startup.
+func (r *CommandRegistry) Banner() string {
+	return "[" + strings.Join(r.Names(), " ") + "] (help [command] for more)"
+}
+
+// Dispatch runs the command of the entry. It returns false if the entry was not a valid command.
+func (r *CommandRegistry) Dispatch(f *Finder, entry string) bool {
+	tokens, err := tokenize(entry)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	if len(tokens) == 0 {
+		return true
+	}
+	name := tokens[0].text
+	cmd := r.Lookup(name)
+	if cmd == nil {
+		fmt.Printf("unknown command %q, try %s\n", name, strings.Join(r.Names(), " "))
+		return false
+	}
+	words := []string{}
+	for _, each := range tokens[1:] {
+		words = append(words, each.text)
+	}
+	in, err := parseInvocation(words, cmd.Flags)
+	if err != nil {
+		fmt.Printf("%v, usage: %s\n", err, cmd.Usage())
+		return false
+	}
+	if len(in.Args) < cmd.MinArgs {
+		fmt.Println("missing argument, usage:", cmd.Usage())
+		return false
+	}
+	cmd.Run(f, in)
+	return true
+}
+
+// Help prints the help of one command or a summary of all.
+func (r *CommandRegistry) Help(name string) {
+	if name != "" {
+		cmd := r.Lookup(name)
+		if cmd == nil {
+			fmt.Printf("unknown command %q\n", name)
+			return
+		}
+		fmt.Println(cmd.Usage())
+		fmt.Println("  " + cmd.Help)
+		for _, each := range cmd.Flags {
+			fmt.Printf("  %s %s\t%s\n", each.Name, each.Value, each.Help)
+		}
+		if len(cmd.Aliases) > 0 {
+			fmt.Println("  aliases:", strings.Join(cmd.Aliases, " "))
+		}
+		return
+	}
+	width := 0
+	for _, each := range r.commands {
+		if w := len(each.Usage()); w > width {
+			width = w
+		}
+	}
+	for _, each := range r.commands {
+		fmt.Printf("%-*s  %s\n", width, each.Usage(), each.Help)
+	}
+}
+
+// completeFolders returns the folder names from the last listing that start with prefix.
+func completeFolders(f *Finder, prefix string) (list []string) {
+	for _, each := range f.lastListing {
+		if isFolder(each) && strings.HasPrefix(each.Name, prefix) {
+			list = append(list, each.Name)
+		}
+	}
+	return
+}
+
+// completeMedia returns the media (non folder) names from the last listing that start with prefix.
+func completeMedia(f *Finder, prefix string) (list []string) {
+	for _, each := range f.lastListing {
+		if !isFolder(each) && strings.HasPrefix(each.Name, prefix) {
+			list = append(list, each.Name)
+		}
+	}
+	return
+}
+
+// completeCommand returns the names of commands that start with prefix.
+func completeCommand(f *Finder, prefix string) (list []string) {
+	for _, each := range commands.AllNames() {
+		if strings.HasPrefix(each, prefix) {
+			list = append(list, each)
+		}
+	}
+	return
+}
+
+var commands = NewCommandRegistry()
+
+var transferFlags = append([]Flag{
+	{Name: "-n", Help: "dry run ; only show what would be done"},
+	{Name: "-i", Help: "ignore case when matching names"},
+	{Name: "-regex", Help: "match names with a regular expression instead of a glob pattern"},
+	{Name: "-R", Help: "also select media in all subfolders"},
+}, filterFlags...)
+
+func transferOptions(in Invocation) (TransferOptions, bool) {
+	filter, err := parseFilter(in)
+	if err != nil {
+		fmt.Println(err)
+		return TransferOptions{}, false
+	}
+	return TransferOptions{
+		DryRun:     in.Has("-n"),
+		IgnoreCase: in.Has("-i"),
+		Regex:      in.Has("-regex"),
+		Recursive:  in.Has("-R"),
+		Filter:     filter,
+	}, true
+}
+
+func init() {
+	commands.Register(&Command{
+		Name: ":q", Aliases: []string{"quit", "exit"},
+		Help: "quit",
+		Run:  func(f *Finder, in Invocation) { f.quit = true },
+	})
+	commands.Register(&Command{
+		Name: ":p",
+		Help: "photo and video listing enabled",
+		Run: func(f *Finder, in Invocation) {
+			f.driveFilesKind = "photos"
+			f.ls()
+		},
+	})
+	commands.Register(&Command{
+		Name: ":f",
+		Help: "folder listing enabled",
+		Run: func(f *Finder, in Invocation) {
+			f.driveFilesKind = "folders"
+			f.ls()
+		},
+	})
+	commands.Register(&Command{
+		Name: ":a",
+		Help: "mixed listing of folders, photos and videos enabled",
+		Run: func(f *Finder, in Invocation) {
+			f.driveFilesKind = "all"
+			f.ls()
+		},
+	})
+	commands.Register(&Command{
+		Name: "cd", Args: "[path|..|-|/]", MinArgs: 1,
+		Help:     "change to the folder by relative path (a/b), absolute path (/a/b), the parent (..), the previous folder (-) or a computer name",
+		Complete: completeFolders,
+		Run:      func(f *Finder, in Invocation) { f.cd(in.Args[0]) },
+	})
+	commands.Register(&Command{
+		Name: "drives",
+		Help: "change to the folder with the shared drives available to you",
+		Run: func(f *Finder, in Invocation) {
+			f.cd("/" + sharedDrivesFolder.Name)
+		},
+	})
+	commands.Register(&Command{
+		Name: "owner", Args: "[mine|shared|any]", MinArgs: 1,
+		Help: "list files in My Drive owned by you, by others or by anyone",
+		Complete: func(f *Finder, prefix string) (list []string) {
+			for _, each := range []string{Owner_Mine, Owner_Shared, Owner_Any} {
+				if strings.HasPrefix(each, prefix) {
+					list = append(list, each)
+				}
+			}
+			return
+		},
+		Run: func(f *Finder, in Invocation) { f.owner(in.Args[0]) },
+	})
+	commands.Register(&Command{
+		Name: "pwd",
+		Help: "print the path of the current folder",
+		Run:  func(f *Finder, in Invocation) { f.pwd() },
+	})
+	commands.Register(&Command{
+		Name: "ls", Aliases: []string{"dir"},
+		Flags: []Flag{
+			{Name: "-l", Help: "long listing with size, dates, capture time, mime type and whether it is on Google Photos"},
+			{Name: "-sort", Value: "name|date|size", Help: "sort the listing"},
+			{Name: "-r", Help: "reverse the sort order"},
+		},
+		Help: "list the contents of the current folder",
+		Run: func(f *Finder, in Invocation) {
+			f.lsWith(ListOptions{Long: in.Has("-l"), SortBy: in.Get("-sort"), Reverse: in.Has("-r")})
+		},
+	})
+	commands.Register(&Command{
+		Name: "cp", Args: "[name|pattern]...", MinArgs: 1,
+		Flags: transferFlags, FlagsInHelp: true,
+		Help:     "copy the media to Google Photos (unless exists)",
+		Complete: completeMedia,
+		Run: func(f *Finder, in Invocation) {
+			opts, ok := transferOptions(in)
+			if !ok || !f.authorize(Operation_Copy) {
+				return
+			}
+			for _, each := range in.Args {
+				f.cp(each, opts)
+			}
+		},
+	})
+	commands.Register(&Command{
+		Name: "rm", Args: "[name|pattern]...", MinArgs: 1,
+		Flags: transferFlags, FlagsInHelp: true,
+		Help:     "remove the media from Google Drive",
+		Complete: completeMedia,
+		Run: func(f *Finder, in Invocation) {
+			opts, ok := transferOptions(in)
+			if ok && f.canDelete() {
+				for _, each := range in.Args {
+					f.rm(each, opts)
+				}
+			}
+			f.ls()
+		},
+	})
+	commands.Register(&Command{
+		Name: "mv", Args: "[name|pattern]...", MinArgs: 1,
+		Flags: transferFlags, FlagsInHelp: true,
+		Help:     "move the media from Google Drive to Google Photos",
+		Complete: completeMedia,
+		Run: func(f *Finder, in Invocation) {
+			opts, ok := transferOptions(in)
+			if ok && f.authorize(Operation_Copy) && f.canDelete() {
+				for _, each := range in.Args {
+					f.mv(each, opts)
+				}
+			}
+			f.ls()
+		},
+	})
+	commands.Register(&Command{
+		Name: "check",
+		Help: "list the files in the current folder that Google Photos would not accept (format or size)",
+		Run:  func(f *Finder, in Invocation) { f.check() },
+	})
+	commands.Register(&Command{
+		Name: "ff", Aliases: []string{"find"}, Args: "[name]...", MinArgs: 1,
+		Help:     "find the media file on Google Photos",
+		Complete: completeMedia,
+		Run: func(f *Finder, in Invocation) {
+			for _, each := range in.Args {
+				f.search(each)
+			}
+		},
+	})
+	commands.Register(&Command{
+		Name: "help", Aliases: []string{"?"}, Args: "[command]",
+		Help:     "show all commands or the help of one",
+		Complete: completeCommand,
+		Run: func(f *Finder, in Invocation) {
+			if len(in.Args) == 0 {
+				commands.Help("")
+				return
+			}
+			commands.Help(in.Args[0])
+		},
+	})
+}
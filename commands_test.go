@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureStdout returns what the function printed.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	fn()
+	w.Close()
+	out, _ := io.ReadAll(r)
+	return string(out)
+}
+
+func TestLookupByNameAndAlias(t *testing.T) {
+	for name, want := range map[string]string{
+		"ls": "ls", "dir": "ls",
+		"help": "help", "?": "help",
+		":q": ":q", "exit": ":q",
+		"ff": "ff", "find": "ff",
+	} {
+		cmd := commands.Lookup(name)
+		if cmd == nil || cmd.Name != want {
+			t.Errorf("Lookup(%q) = %v, want %s", name, cmd, want)
+		}
+	}
+	if commands.Lookup("cpx") != nil {
+		t.Error("cpx is not a command")
+	}
+}
+
+func TestDispatchUnknownCommand(t *testing.T) {
+	var ok bool
+	out := captureStdout(t, func() { ok = commands.Dispatch(new(Finder), "cpx IMG.jpg") })
+	if ok {
+		t.Error("expected false")
+	}
+	if !strings.Contains(out, `unknown command "cpx"`) {
+		t.Errorf("got %q", out)
+	}
+}
+
+func TestDispatchMissingArgument(t *testing.T) {
+	var ok bool
+	out := captureStdout(t, func() { ok = commands.Dispatch(new(Finder), "rm") })
+	if ok {
+		t.Error("expected false")
+	}
+	if !strings.Contains(out, "missing argument") {
+		t.Errorf("got %q", out)
+	}
+}
+
+func TestDispatchRunsWithArgsAndFlags(t *testing.T) {
+	r := NewCommandRegistry()
+	var got Invocation
+	r.Register(&Command{
+		Name: "cp", Aliases: []string{"copy"}, MinArgs: 1,
+		Flags: []Flag{{Name: "-n"}, {Name: "-since", Value: "date"}},
+		Run:   func(f *Finder, in Invocation) { got = in },
+	})
+	if !r.Dispatch(nil, `copy -n --since=2019-01-01 "My Trip.jpg" b.jpg`) {
+		t.Fatal("expected true")
+	}
+	if !got.Has("-n") || got.Get("-since") != "2019-01-01" {
+		t.Errorf("flags: got %v", got.Flags)
+	}
+	if strings.Join(got.Args, "|") != "My Trip.jpg|b.jpg" {
+		t.Errorf("args: got %q", got.Args)
+	}
+	out := captureStdout(t, func() {
+		if r.Dispatch(nil, "cp -x a.jpg") {
+			t.Error("expected false for unknown flag")
+		}
+	})
+	if !strings.Contains(out, "unknown flag -x") {
+		t.Errorf("got %q", out)
+	}
+}
+
+func TestDispatchEmptyEntry(t *testing.T) {
+	if !commands.Dispatch(nil, "   ") {
+		t.Error("an empty entry is valid")
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for name, want := range map[string]string{
+		"ls": "ls [-l] [-sort name|date|size] [-r]",
+		"cp": "cp [flags] [name|pattern]...",
+		"cd": "cd [path|..|-|/]",
+	} {
+		if got := commands.Lookup(name).Usage(); got != want {
+			t.Errorf("got %q want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/peterh/liner"
+)
+
+// WordCompleter returns a liner completer that offers command names and,
+// after a command, the candidates of that command (e.g. the entries of the last listing).
+func (r *CommandRegistry) WordCompleter(f *Finder) liner.WordCompleter {
+	return func(line string, pos int) (head string, completions []string, tail string) {
+		// pos counts runes, not bytes
+		runes := []rune(line)
+		before, tail := string(runes[:pos]), string(runes[pos:])
+		tokens, _ := tokenize(before)
+		if len(tokens) == 0 {
+			return before, completeCommand(f, ""), tail
+		}
+		// completing the last word unless a new one is started
+		last := tokens[len(tokens)-1]
+		if probe, _ := tokenize(before + "x"); probe[len(probe)-1].offset == len(before) {
+			last = token{offset: len(before)}
+		}
+		head = before[:last.offset]
+		if last.offset == 0 {
+			return head, completeCommand(f, last.text), tail
+		}
+		cmd := r.Lookup(tokens[0].text)
+		if cmd == nil || cmd.Complete == nil {
+			return before, nil, tail
+		}
+		for _, each := range cmd.Complete(f, last.text) {
+			completions = append(completions, quoteIfNeeded(each))
+		}
+		return head, completions, tail
+	}
+}
+
+// quoteIfNeeded puts double quotes around a name with spaces or quotes.
+func quoteIfNeeded(name string) string {
+	if !strings.ContainsAny(name, " \t\"'\\") {
+		return name
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+)
+
+func TestWordCompleterNonASCII(t *testing.T) {
+	f := new(Finder)
+	f.lastListing = []*drive.File{
+		{Name: "Écoles", MimeType: folderMimeType},
+		{Name: "Économie 2020", MimeType: folderMimeType},
+		{Name: "Eco", MimeType: folderMimeType},
+		{Name: "Éco.jpg", MimeType: "image/jpeg"},
+	}
+	complete := commands.WordCompleter(f)
+	for _, each := range []struct {
+		line        string
+		pos         int
+		head, tail  string
+		completions []string
+	}{
+		{"cd Éco", 6, "cd ", "", []string{"Écoles", `"Économie 2020"`}},
+		{"cd Éco x", 6, "cd ", " x", []string{"Écoles", `"Économie 2020"`}},
+		{"cd É", 4, "cd ", "", []string{"Écoles", `"Économie 2020"`}},
+		{"cd Ec", 5, "cd ", "", []string{"Eco"}},
+	} {
+		head, completions, tail := complete(each.line, each.pos)
+		if head != each.head || tail != each.tail || !reflect.DeepEqual(completions, each.completions) {
+			t.Errorf("%q at %d: got %q %q %q, want %q %q %q", each.line, each.pos,
+				head, completions, tail, each.head, each.completions, each.tail)
+		}
+	}
+}
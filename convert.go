@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	"google.golang.org/api/drive/v3"
+)
+
+// Converter turns media that Google Photos does not accept into a format that it does.
+type Converter interface {
+	// Accepts returns whether the media of this mime type can be converted.
+	Accepts(mimeType string) bool
+	// Target returns the mime type and file extension of the converted media.
+	Target() (mimeType, ext string)
+	Convert(content []byte) ([]byte, error)
+}
+
+// defaultConverters returns the built-in converters and those of the external tools that are installed.
+func defaultConverters() (list []Converter) {
+	list = append(list, imageConverter{quality: 92})
+	if path, err := exec.LookPath("ffmpeg"); err == nil {
+		list = append(list, externalConverter{
+			prefixes: []string{"video/"},
+			mimeType: "video/mp4", ext: ".mp4",
+			command: []string{path, "-y", "-loglevel", "error", "-i", "{in}", "-map_metadata", "0", "{out}"},
+		})
+	}
+	// not the older convert command of ImageMagick 6 ; on Windows that name is the disk tool
+	if path, err := exec.LookPath("magick"); err == nil {
+		list = append(list, externalConverter{
+			prefixes: []string{"image/"}, // e.g. PSD and SVG
+			mimeType: "image/jpeg", ext: ".jpg",
+			// [0] is the flattened image of a PSD
+			command: []string{path, "{in}[0]", "{out}"},
+		})
+	}
+	return
+}
+
+// imageConverter converts PNG, TIFF and BMP images to JPEG.
+// Google Photos accepts these formats, so it is only used for images over the size limit.
+type imageConverter struct {
+	quality int
+}
+
+func (c imageConverter) Accepts(mimeType string) bool {
+	switch mimeType {
+	case "image/png", "image/tiff", "image/bmp", "image/x-ms-bmp":
+		return true
+	}
+	return false
+}
+
+func (c imageConverter) Target() (string, string) { return "image/jpeg", ".jpg" }
+
+func (c imageConverter) Convert(content []byte) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	// JPEG has no transparency ; flatten onto white instead of black
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+	out := new(bytes.Buffer)
+	if err := jpeg.Encode(out, flat, &jpeg.Options{Quality: c.quality}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// externalConverter runs a command in which {in} and {out} are replaced by the paths of temporary files.
+type externalConverter struct {
+	prefixes []string // of accepted mime types
+	mimeType string
+	ext      string
+	command  []string
+}
+
+func (c externalConverter) Accepts(mimeType string) bool {
+	for _, each := range c.prefixes {
+		if strings.HasPrefix(mimeType, each) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c externalConverter) Target() (string, string) { return c.mimeType, c.ext }
+
+func (c externalConverter) Convert(content []byte) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "drive2photos")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	in, out := filepath.Join(dir, "in"), filepath.Join(dir, "out"+c.ext)
+	if err := os.WriteFile(in, content, 0600); err != nil {
+		return nil, err
+	}
+	args := make([]string, len(c.command))
+	for i, each := range c.command {
+		args[i] = strings.NewReplacer("{in}", in, "{out}", out).Replace(each)
+	}
+	if output, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%s failed: %v: %s", filepath.Base(args[0]), err, bytes.TrimSpace(output))
+	}
+	return os.ReadFile(out)
+}
+
+// converterFor returns the converter for a file that Google Photos would not accept as is, because of its format or size.
+func (f *Finder) converterFor(file *drive.File) (Converter, bool) {
+	if validateUpload(file) == nil {
+		return nil, false
+	}
+	for _, each := range f.converters {
+		if each.Accepts(file.MimeType) {
+			return each, true
+		}
+	}
+	return nil, false
+}
+
+// convertedFile returns the metadata of the file after conversion ; its size is not known yet.
+func convertedFile(file *drive.File, c Converter) *drive.File {
+	mimeType, ext := c.Target()
+	converted := *file
+	converted.Name = strings.TrimSuffix(file.Name, filepath.Ext(file.Name)) + ext
+	converted.MimeType = mimeType
+	converted.Size = 0
+	return &converted
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestImageConverterFlattensTransparencyOntoWhite(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 8, 8)) // fully transparent
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, src); err != nil {
+		t.Fatal(err)
+	}
+	out, err := imageConverter{quality: 92}.Convert(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, g, b, _ := img.At(4, 4).RGBA()
+	if r>>8 < 250 || g>>8 < 250 || b>>8 < 250 {
+		t.Errorf("got %v, want white", color.RGBA64{uint16(r), uint16(g), uint16(b), 0xffff})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// maxDescriptionLength is the maximum number of characters of a media item description in Google Photos.
+const maxDescriptionLength = 1000
+
+const defaultDescriptionTemplate = `{{.File.Description}}
+{{date .File.ModifiedTime}}`
+
+// DescriptionData is what the description template can use.
+type DescriptionData struct {
+	File             *drive.File
+	Folder           string // Drive path of the folder of the file
+	Owner            string // email address
+	OriginalFilename string
+	Converted        bool // whether the media was converted to a format that Google Photos accepts
+}
+
+// convertedMarker is added to the description of converted media, after the original filename.
+const convertedMarker = "-converted"
+
+// Describer renders the description of uploaded media items.
+type Describer struct {
+	tmpl *template.Template
+}
+
+// NewDescriber parses the template ; an empty template means no descriptions.
+func NewDescriber(text string) (*Describer, error) {
+	if text == "" {
+		return &Describer{}, nil
+	}
+	tmpl, err := template.New("description").Funcs(template.FuncMap{
+		"date": formatDriveTime("2006-01-02 15:04"),
+		"day":  formatDriveTime("2006-01-02"),
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid description template: %v", err)
+	}
+	return &Describer{tmpl: tmpl}, nil
+}
+
+// Describe returns the description, without surrounding whitespace and at most maxDescriptionLength characters.
+func (d *Describer) Describe(data DescriptionData) (string, error) {
+	if d == nil || d.tmpl == nil {
+		return "", nil
+	}
+	b := new(bytes.Buffer)
+	if err := d.tmpl.Execute(b, data); err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(b.String())
+	suffix := ""
+	if data.Converted {
+		suffix = data.OriginalFilename + " " + convertedMarker
+		if text != "" {
+			suffix = "\n" + suffix
+		}
+	}
+	if runes := []rune(text); len(runes) > maxDescriptionLength-len([]rune(suffix)) {
+		text = string(runes[:maxDescriptionLength-len([]rune(suffix))-1]) + "…"
+	}
+	return text + suffix, nil
+}
+
+// formatDriveTime returns a template function that formats an RFC3339 Drive time in local time.
+func formatDriveTime(layout string) func(string) string {
+	return func(rfc3339 string) string {
+		t, err := time.Parse(time.RFC3339, rfc3339)
+		if err != nil {
+			return rfc3339
+		}
+		return t.Local().Format(layout)
+	}
+}
+
+// describe returns the description for the file, printing a problem with the template.
+func (f *Finder) describe(file *drive.File, converted bool) string {
+	owner := f.drive.owner
+	if len(file.Owners) > 0 && file.Owners[0].EmailAddress != "" {
+		owner = file.Owners[0].EmailAddress
+	}
+	original := file.OriginalFilename
+	if original == "" {
+		original = file.Name
+	}
+	text, err := f.describer.Describe(DescriptionData{
+		File:             file,
+		Folder:           f.filePath(file),
+		Owner:            owner,
+		OriginalFilename: original,
+		Converted:        converted,
+	})
+	if err != nil {
+		fmt.Println("unable to render description:", err)
+	}
+	return text
+}
@@ -12,80 +12,195 @@ import (
 
 // https://developers.google.com/drive/api/guides/ref-search-terms
 type DriveService struct {
-	service *drive.Service
-	owner   string
-	client  *http.Client
+	service     *drive.Service
+	owner       string
+	ownerFilter string
+	client      *http.Client
+	shared      map[string]bool // ids of folders reached through the Shared with me folder
 }
 
-func (s *DriveService) FolderByName(dir string) *drive.File {
-	call := s.service.Files.List().Q(fmt.Sprintf("name = '%s'", dir))
+// Choices for the owner of listed files in My Drive.
+const (
+	Owner_Mine   = "mine"
+	Owner_Shared = "shared"
+	Owner_Any    = "any"
+)
+
+// ComputerFolders returns the top level folders of backed up computers with that name.
+// Unlike folders in My Drive, these have no parent ; folders shared with me have none either, so only owned ones are kept.
+func (s *DriveService) ComputerFolders(name string) (list []*drive.File) {
+	q := NewQuery().Name(name).Folder().Trashed(false).Owner(s.owner)
+	call := s.service.Files.List().Q(q.String()).Fields("files(id, name, parents)")
 	f, err := call.Do()
 	if err != nil {
 		fmt.Printf("Unable to retrieve files: %v\n", err)
 		return nil
 	}
-	if len(f.Files) == 0 {
+	for _, each := range f.Files {
+		if len(each.Parents) == 0 {
+			list = append(list, each)
+		}
+	}
+	return list
+}
+
+// ChildFolders returns the folders in the parent with that name ; Drive allows more than one.
+func (s *DriveService) ChildFolders(parent *drive.File, name string) []*drive.File {
+	q := s.inFolder(parent).Name(name).Folder().Trashed(false)
+	call := s.list(parent, q).Fields("files(id, name, driveId)")
+	f, err := call.Do()
+	if err != nil {
+		fmt.Printf("Unable to retrieve files: %v\n", err)
 		return nil
 	}
-	return f.Files[0]
+	s.markShared(parent, f.Files)
+	return f.Files
 }
 
-func (s *DriveService) Delete(f *drive.File) bool {
+func (s *DriveService) Delete(f *drive.File) error {
 	fmt.Println("deleting", f.Name)
 
-	err := s.service.Files.Delete(f.Id).Do()
+	err := s.service.Files.Delete(f.Id).SupportsAllDrives(true).Do()
 	if err != nil {
-		fmt.Printf("unable to delete file: %v/n", err)
-		return false
+		return fmt.Errorf("unable to delete file: %v", err)
 	}
-	return true
+	return nil
 }
 
-func (s *DriveService) Download(f *drive.File) ([]byte, bool) {
-	fmt.Println("downloading", f.Name)
+func (s *DriveService) Download(f *drive.File, progress *Progress) ([]byte, error) {
+	if progress == nil {
+		fmt.Println("downloading", f.Name)
+	}
 
-	resp, err := s.service.Files.Get(f.Id).Download()
+	resp, err := s.service.Files.Get(f.Id).SupportsAllDrives(true).Download()
 	if err != nil {
-		fmt.Printf("unable to download file: %v/n", err)
-		return nil, false
+		return nil, fmt.Errorf("unable to download file: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		fmt.Printf("unable to download file: %v/n", resp.Status)
-		return nil, false
+		return nil, &HTTPError{Op: "download", StatusCode: resp.StatusCode, Status: resp.Status}
 	}
-	data, err := io.ReadAll(resp.Body)
+	progress.Begin("downloading", f.Name, f.Size)
+	data, err := io.ReadAll(progress.Reader(resp.Body))
+	progress.End()
 	if err != nil {
-		fmt.Printf("unable to download file: %v/n", err)
-		return nil, false
+		return nil, fmt.Errorf("unable to download file: %w", err)
+	}
+	return data, nil
+}
+
+const (
+	sharedDrivesID = "shared-drives"
+	sharedWithMeID = "shared-with-me"
+)
+
+// sharedWithMeFolder is the virtual folder in the root with the files and folders others shared with the user.
+var sharedWithMeFolder = &drive.File{Id: sharedWithMeID, Name: "Shared with me", MimeType: folderMimeType}
+
+// sharedDrivesFolder is the virtual folder in the root that has the shared drives as subfolders.
+var sharedDrivesFolder = &drive.File{Id: sharedDrivesID, Name: "Shared drives", MimeType: folderMimeType}
+
+// SharedDrives returns the shared drives the user has access to, as folders.
+func (s *DriveService) SharedDrives() (list []*drive.File) {
+	pageToken := ""
+	for {
+		r, err := s.service.Drives.List().PageSize(100).PageToken(pageToken).Do()
+		if err != nil {
+			fmt.Printf("Unable to retrieve shared drives: %v\n", err)
+			return
+		}
+		for _, each := range r.Drives {
+			list = append(list, &drive.File{
+				Id:          each.Id,
+				DriveId:     each.Id,
+				Name:        each.Name,
+				MimeType:    folderMimeType,
+				CreatedTime: each.CreatedTime,
+			})
+		}
+		pageToken = r.NextPageToken
+		if pageToken == "" {
+			return
+		}
+	}
+}
+
+// list returns the call to list files in the parent folder.
+// Files in My Drive must be owned by the user ; files in a shared drive are owned by that drive.
+func (s *DriveService) list(parent *drive.File, q *Query) *drive.FilesListCall {
+	call := s.service.Files.List()
+	if parent.DriveId == "" {
+		return call.Q(q.String())
+	}
+	return call.Q(q.String()).
+		Corpora("drive").
+		DriveId(parent.DriveId).
+		IncludeItemsFromAllDrives(true).
+		SupportsAllDrives(true)
+}
+
+// inFolder returns the query for files in the parent folder, which can be the virtual Shared with me folder.
+func (s *DriveService) inFolder(parent *drive.File) *Query {
+	if parent.Id == sharedWithMeID {
+		return NewQuery().SharedWithMe()
+	}
+	return NewQuery().InParents(parent.Id)
+}
+
+// listQuery returns the query for listing files in the parent folder, applying the owner filter in My Drive.
+func (s *DriveService) listQuery(parent *drive.File) *Query {
+	q := s.inFolder(parent).Trashed(false)
+	if parent.DriveId != "" || s.isShared(parent) {
+		return q
 	}
-	return data, true
+	switch s.ownerFilter {
+	case Owner_Shared:
+		q.NotOwner(s.owner)
+	case Owner_Any:
+	default:
+		q.Owner(s.owner)
+	}
+	return q
+}
+
+// isShared returns whether the folder is Shared with me or below it ; its files are owned by others.
+func (s *DriveService) isShared(folder *drive.File) bool {
+	return folder.Id == sharedWithMeID || s.shared[folder.Id]
 }
 
-func (s *DriveService) Folders(parent string) (list []*drive.File) {
+// markShared remembers the folders listed in a shared folder, so their files are listed regardless of the owner filter.
+func (s *DriveService) markShared(parent *drive.File, folders []*drive.File) {
+	if !s.isShared(parent) {
+		return
+	}
+	if s.shared == nil {
+		s.shared = map[string]bool{}
+	}
+	for _, each := range folders {
+		s.shared[each.Id] = true
+	}
+}
+
+func (s *DriveService) Folders(parent *drive.File) (list []*drive.File) {
 	done := false
 	pageToken := ""
 	for !done {
-		r, err := s.service.Files.List().
-			Q(fmt.Sprintf(`
-		'%s' in parents and
-		mimeType = 'application/vnd.google-apps.folder' and 
-		trashed=false and 
-		'%s' in owners
-		`, parent, s.owner)).
+		q := s.listQuery(parent).Folder()
+		r, err := s.list(parent, q).
 			PageToken(pageToken).
 			PageSize(100).
-			Fields("nextPageToken, files(id, name)").Do()
+			Fields("nextPageToken, files(id, name, mimeType, createdTime, modifiedTime, driveId)").Do()
 		if err != nil {
 			if uerr, ok := err.(*url.Error); ok {
 				if oerr, ok := uerr.Err.(*oauth2.RetrieveError); ok {
 					if oerr.ErrorCode == "invalid_grant" {
-						fmt.Println("Your saved access token (token.json) is no longer valid ; retry after deleting it")
+						fmt.Println("Your saved authorization is no longer valid and was removed ; retry to authorize again")
 						return list
 					}
 				}
 			}
 			fmt.Printf("Unable to retrieve files: %v (%T)\n", err, err)
+			return list
 		}
 		list = append(list, r.Files...)
 		pageToken = r.NextPageToken
@@ -93,34 +208,46 @@ func (s *DriveService) Folders(parent string) (list []*drive.File) {
 			done = true
 		}
 	}
+	s.markShared(parent, list)
 	return
 }
 
 // https://developers.google.com/drive/api/reference/rest/v3/files
-func (s *DriveService) Photos(parent string) (list []*drive.File) {
+func (s *DriveService) Photos(parent *drive.File) (list []*drive.File) {
+	return s.PhotosMatching(parent, Filter{})
+}
+
+// PhotosMatching returns the photos and videos in the parent folder, with the date range of the filter evaluated by Drive.
+// Whether Google Photos accepts their format is decided by validateUpload.
+func (s *DriveService) PhotosMatching(parent *drive.File, filter Filter) (list []*drive.File) {
+	return s.files(parent, filter.Query(s.listQuery(parent).
+		Any(NewQuery().MimeTypeContains("image/"), NewQuery().MimeTypeContains("video/"), NewQuery().NameContains(".JPG"))))
+}
+
+// Files returns all files, except folders, in the parent folder.
+func (s *DriveService) Files(parent *drive.File) (list []*drive.File) {
+	return s.files(parent, s.listQuery(parent).NotMimeType(folderMimeType))
+}
+
+func (s *DriveService) files(parent *drive.File, q *Query) (list []*drive.File) {
 	done := false
 	pageToken := ""
 	for !done {
-		r, err := s.service.Files.List().
-			Q(fmt.Sprintf(`
-		'%s' in parents and
-		(mimeType = 'image/png' or mimeType = 'image/jpeg' or name contains '.JPG') and 
-		trashed=false and 
-		'%s' in owners
-		`, parent, s.owner)).
+		r, err := s.list(parent, q).
 			PageSize(100).
 			PageToken(pageToken).
-			Fields("nextPageToken, files(id, name,createdTime,modifiedTime,modifiedByMeTime,originalFilename)").Do()
+			Fields("nextPageToken, files(id, name,createdTime,modifiedTime,modifiedByMeTime,originalFilename,mimeType,size,imageMediaMetadata(time,cameraMake,cameraModel),driveId,capabilities(canTrash,canDelete),description,owners(emailAddress))").Do()
 		if err != nil {
 			if uerr, ok := err.(*url.Error); ok {
 				if oerr, ok := uerr.Err.(*oauth2.RetrieveError); ok {
 					if oerr.ErrorCode == "invalid_grant" {
-						fmt.Println("Your saved access token (token.json) is no longer valid ; retry after deleting it")
+						fmt.Println("Your saved authorization is no longer valid and was removed ; retry to authorize again")
 						return list
 					}
 				}
 			}
 			fmt.Printf("Unable to retrieve files: %v\n", err)
+			return list
 		}
 		list = append(list, r.Files...)
 		pageToken = r.NextPageToken
@@ -130,3 +257,35 @@ func (s *DriveService) Photos(parent string) (list []*drive.File) {
 	}
 	return
 }
+
+// StartPageToken returns the token to list changes made from now on.
+func (s *DriveService) StartPageToken() (string, error) {
+	r, err := s.service.Changes.GetStartPageToken().SupportsAllDrives(true).Do()
+	if err != nil {
+		return "", err
+	}
+	return r.StartPageToken, nil
+}
+
+// Changes returns one page of changes since the page token.
+// https://developers.google.com/drive/api/reference/rest/v3/changes/list
+func (s *DriveService) Changes(pageToken string) (*drive.ChangeList, error) {
+	return s.service.Changes.List(pageToken).
+		PageSize(100).
+		IncludeItemsFromAllDrives(true).
+		SupportsAllDrives(true).
+		Fields("nextPageToken, newStartPageToken, changes(removed, fileId, file(" + mediaFields + "))").Do()
+}
+
+// mediaFields are the fields of a file that a transfer needs.
+const mediaFields = "id, name,createdTime,modifiedTime,originalFilename,mimeType,size,imageMediaMetadata(time,cameraMake,cameraModel),driveId,parents,trashed,description,owners(emailAddress)"
+
+// File returns the file with that id ; "root" returns My Drive with its real id.
+func (s *DriveService) File(id string) (*drive.File, error) {
+	return s.service.Files.Get(id).SupportsAllDrives(true).Fields(mediaFields).Do()
+}
+
+// Folder returns the folder with its name and the ids of its parent folders.
+func (s *DriveService) Folder(id string) (*drive.File, error) {
+	return s.service.Files.Get(id).SupportsAllDrives(true).Fields("id, name, parents").Do()
+}
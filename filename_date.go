@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FilenameDateParser finds the capture date in filenames using regular expressions
+// with the named groups year, month and day and optionally hour, minute, second and ampm (AM or PM, for a 12-hour clock).
+// The first matching pattern wins ; user defined patterns are tried before the built-in ones.
+type FilenameDateParser struct {
+	user     []*regexp.Regexp
+	builtins []*regexp.Regexp
+}
+
+var builtinFilenameDates = []string{
+	// IMG_20170812_143501.jpg, VID_20170812_143501.mp4, PXL_20210304_101112345.jpg, MVIMG_20190101_120000.jpg
+	`(?:IMG|VID|PXL|MVIMG)_(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})_(?P<hour>\d{2})(?P<minute>\d{2})(?P<second>\d{2})`,
+	// WhatsApp Image 2019-05-01 at 10.22.11.jpeg, WhatsApp Image 2019-05-01 at 9.22.11 PM.jpeg
+	`WhatsApp (?:Image|Video) (?P<year>\d{4})-(?P<month>\d{2})-(?P<day>\d{2}) at (?P<hour>\d{1,2})\.(?P<minute>\d{2})\.(?P<second>\d{2})(?: (?P<ampm>[AP]M))?`,
+	// Screenshot_2020-01-31-10-22-11.png, Screenshot_20200131-102211.png, Screenshot 2020-01-31 at 10.22.11.png
+	`Screenshot[_ ](?P<year>\d{4})-?(?P<month>\d{2})-?(?P<day>\d{2})(?:[-_ ](?:at )?(?P<hour>\d{2})[-.]?(?P<minute>\d{2})[-.]?(?P<second>\d{2}))?`,
+	// any 2017-08-12 or 20170812, optionally followed by a time
+	`(?P<year>(?:19|20)\d{2})-?(?P<month>[01]\d)-?(?P<day>[0-3]\d)(?:[_ T-]?(?P<hour>[0-2]\d)[.:-]?(?P<minute>[0-5]\d)[.:-]?(?P<second>[0-5]\d))?`,
+}
+
+func NewFilenameDateParser() *FilenameDateParser {
+	p := new(FilenameDateParser)
+	for _, each := range builtinFilenameDates {
+		p.builtins = append(p.builtins, regexp.MustCompile(each))
+	}
+	return p
+}
+
+// Add registers a user defined pattern ; it must have the groups year, month and day.
+func (p *FilenameDateParser) Add(expr string) error {
+	r, err := regexp.Compile(expr)
+	if err != nil {
+		return err
+	}
+	for _, each := range []string{"year", "month", "day"} {
+		if r.SubexpIndex(each) == -1 {
+			return fmt.Errorf("pattern %q has no group (?P<%s>...)", expr, each)
+		}
+	}
+	p.user = append(p.user, r)
+	return nil
+}
+
+// Parse returns the local time found in the filename.
+func (p *FilenameDateParser) Parse(filename string) (time.Time, bool) {
+	for _, each := range append(append([]*regexp.Regexp{}, p.user...), p.builtins...) {
+		if t, ok := parseWith(each, filename); ok {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func parseWith(r *regexp.Regexp, filename string) (time.Time, bool) {
+	m := r.FindStringSubmatch(filename)
+	if m == nil {
+		return time.Time{}, false
+	}
+	group := func(name string) int {
+		i := r.SubexpIndex(name)
+		if i == -1 || m[i] == "" {
+			return 0
+		}
+		n, _ := strconv.Atoi(m[i])
+		return n
+	}
+	year, month, day := group("year"), group("month"), group("day")
+	hour, minute, second := group("hour"), group("minute"), group("second")
+	if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || minute > 59 || second > 59 {
+		return time.Time{}, false
+	}
+	if i := r.SubexpIndex("ampm"); i != -1 && m[i] != "" {
+		if hour < 1 || hour > 12 {
+			return time.Time{}, false
+		}
+		// 12 AM is midnight and 12 PM is noon
+		hour %= 12
+		if strings.EqualFold(m[i], "PM") {
+			hour += 12
+		}
+	}
+	t := time.Date(year, time.Month(month), day, hour, minute, second, 0, time.Local)
+	// reject overflow such as 31 February and dates that cannot be a capture date
+	if t.Day() != day || t.Year() < 1900 || t.After(time.Now()) {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// filenameDates is the parser used for all files ; see the -filename-date flag.
+var filenameDates = NewFilenameDateParser()
+
+// filenameDateFlag adds user defined patterns to filenameDates, one per occurrence of the flag.
+type filenameDateFlag struct{}
+
+func (filenameDateFlag) String() string {
+	list := []string{}
+	for _, each := range filenameDates.user {
+		list = append(list, each.String())
+	}
+	return strings.Join(list, ",")
+}
+
+func (filenameDateFlag) Set(expr string) error {
+	return filenameDates.Add(expr)
+}
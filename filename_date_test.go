@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilenameDateParser(t *testing.T) {
+	p := NewFilenameDateParser()
+	next := time.Now().Year() + 1
+	for _, each := range []struct {
+		name string
+		want string // empty if rejected
+	}{
+		{"IMG_20170812_143501.jpg", "2017-08-12 14:35:01"},
+		{"VID_20170812_143501.mp4", "2017-08-12 14:35:01"},
+		{"PXL_20210304_101112345.jpg", "2021-03-04 10:11:12"},
+		{"WhatsApp Image 2019-05-01 at 10.22.11.jpeg", "2019-05-01 10:22:11"},
+		{"WhatsApp Image 2019-05-01 at 9.22.11 PM.jpeg", "2019-05-01 21:22:11"},
+		{"WhatsApp Video 2019-05-01 at 9.22.11 AM.mp4", "2019-05-01 09:22:11"},
+		{"WhatsApp Image 2019-05-01 at 12.05.00 AM.jpeg", "2019-05-01 00:05:00"},
+		{"WhatsApp Image 2019-05-01 at 12.05.00 PM.jpeg", "2019-05-01 12:05:00"},
+		// an invalid 12-hour time leaves only the date
+		{"WhatsApp Image 2019-05-01 at 13.05.00 PM.jpeg", "2019-05-01 00:00:00"},
+		{"Screenshot_2020-01-31-10-22-11.png", "2020-01-31 10:22:11"},
+		{"Screenshot_20200131-102211.png", "2020-01-31 10:22:11"},
+		{"Screenshot 2020-01-31 at 10.22.11.png", "2020-01-31 10:22:11"},
+		{"holiday 2017-08-12.jpg", "2017-08-12 00:00:00"},
+		{"20170812.jpg", "2017-08-12 00:00:00"},
+		{"IMG_20170230_120000.jpg", ""},
+		{"scan 2017-02-30.jpg", ""},
+		{"IMG_" + time.Date(next, 1, 1, 0, 0, 0, 0, time.Local).Format("20060102") + "_120000.jpg", ""},
+		{"IMG_0001.jpg", ""},
+	} {
+		got, ok := p.Parse(each.name)
+		if each.want == "" {
+			if ok {
+				t.Errorf("%q: got %v, want rejected", each.name, got)
+			}
+			continue
+		}
+		if !ok || got.Format("2006-01-02 15:04:05") != each.want || got.Location() != time.Local {
+			t.Errorf("%q: got %v %v, want %s", each.name, got, ok, each.want)
+		}
+	}
+}
+
+func TestFilenameDateParserUserPattern(t *testing.T) {
+	p := NewFilenameDateParser()
+	if err := p.Add(`scan_(?P<day>\d{2})(?P<month>\d{2})`); err == nil {
+		t.Error("expected error for pattern without year")
+	}
+	if err := p.Add(`scan_(?P<day>\d{2})(?P<month>\d{2})(?P<year>\d{4})`); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := p.Parse("scan_31122016.jpg")
+	if !ok || got.Format("2006-01-02") != "2016-12-31" {
+		t.Errorf("got %v %v", got, ok)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// Filter selects media by modification date, size and camera.
+// The date range is pushed down into the Drive query ; size and camera are checked on the listed files
+// because Drive cannot search on them.
+type Filter struct {
+	Since   time.Time // inclusive
+	Until   time.Time // inclusive, the whole day
+	MinSize int64
+	MaxSize int64
+	Camera  string // part of the camera make or model, ignoring case
+}
+
+var filterFlags = []Flag{
+	{Name: "-since", Value: "yyyy-mm-dd", Help: "only media modified on or after the date"},
+	{Name: "-until", Value: "yyyy-mm-dd", Help: "only media modified on or before the date"},
+	{Name: "-min-size", Value: "size", Help: "only media of at least the size, e.g. 100KB"},
+	{Name: "-max-size", Value: "size", Help: "only media of at most the size, e.g. 20MB"},
+	{Name: "-camera", Value: "text", Help: "only photos taken with a camera whose make or model contains the text"},
+}
+
+// parseFilter returns the Filter from the filter flags of the invocation.
+func parseFilter(in Invocation) (filter Filter, err error) {
+	if v := in.Get("-since"); v != "" {
+		if filter.Since, err = time.ParseInLocation("2006-01-02", v, time.Local); err != nil {
+			return filter, fmt.Errorf("invalid -since date %q, use yyyy-mm-dd", v)
+		}
+	}
+	if v := in.Get("-until"); v != "" {
+		if filter.Until, err = time.ParseInLocation("2006-01-02", v, time.Local); err != nil {
+			return filter, fmt.Errorf("invalid -until date %q, use yyyy-mm-dd", v)
+		}
+	}
+	if v := in.Get("-min-size"); v != "" {
+		if filter.MinSize, err = parseSize(v); err != nil {
+			return filter, err
+		}
+	}
+	if v := in.Get("-max-size"); v != "" {
+		if filter.MaxSize, err = parseSize(v); err != nil {
+			return filter, err
+		}
+	}
+	filter.Camera = in.Get("-camera")
+	return filter, nil
+}
+
+// IsEmpty returns whether the filter selects everything.
+func (f Filter) IsEmpty() bool {
+	return f == Filter{}
+}
+
+// Query adds the clauses that Drive can evaluate.
+func (f Filter) Query(q *Query) *Query {
+	if !f.Since.IsZero() {
+		q.ModifiedAfter(f.Since)
+	}
+	if !f.Until.IsZero() {
+		q.ModifiedBefore(f.Until.AddDate(0, 0, 1))
+	}
+	return q
+}
+
+// Match returns whether the file passes all conditions.
+func (f Filter) Match(file *drive.File) bool {
+	if !f.Since.IsZero() || !f.Until.IsZero() {
+		modified, err := time.Parse(time.RFC3339, file.ModifiedTime)
+		if err != nil {
+			return false
+		}
+		if !f.Since.IsZero() && modified.Before(f.Since) {
+			return false
+		}
+		if !f.Until.IsZero() && !modified.Before(f.Until.AddDate(0, 0, 1)) {
+			return false
+		}
+	}
+	if f.MinSize > 0 && file.Size < f.MinSize {
+		return false
+	}
+	if f.MaxSize > 0 && file.Size > f.MaxSize {
+		return false
+	}
+	if f.Camera != "" {
+		meta := file.ImageMediaMetadata
+		if meta == nil {
+			return false
+		}
+		camera := strings.ToLower(meta.CameraMake + " " + meta.CameraModel)
+		if !strings.Contains(camera, strings.ToLower(f.Camera)) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseSize parses a number of bytes with an optional unit: B, KB, MB, GB (powers of 1024).
+func parseSize(s string) (int64, error) {
+	text := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, each := range []struct {
+		suffix string
+		factor int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(text, each.suffix) {
+			text = strings.TrimSpace(strings.TrimSuffix(text, each.suffix))
+			multiplier = each.factor
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, use e.g. 500KB or 2MB", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
@@ -1,45 +1,174 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"regexp"
 	"strings"
 	"time"
 
 	"google.golang.org/api/drive/v3"
 )
 
+// ListOptions are the flags of the ls command.
+type ListOptions struct {
+	Long    bool
+	SortBy  string
+	Reverse bool
+}
+
+// TransferOptions are the flags of the cp, rm and mv commands.
+type TransferOptions struct {
+	DryRun     bool
+	Regex      bool
+	IgnoreCase bool
+	Recursive  bool
+	Filter     Filter
+}
+
 func (f *Finder) ls() {
-	found := false
-	if f.driveFilesKind == "folders" {
-		f.lastListing = f.drive.Folders(f.driveStack.Top().Id)
-		for _, each := range f.lastListing {
-			found = true
-			fmt.Println(each.Name)
-		}
-		if !found {
-			fmt.Println("no folders found")
+	f.lsWith(ListOptions{})
+}
+
+func (f *Finder) lsWith(opts ListOptions) {
+	parent := f.driveStack.Top()
+	switch {
+	case parent.Id == sharedDrivesID:
+		if !f.authorize(Operation_Drives) {
+			return
 		}
+		f.lastListing = f.drive.SharedDrives()
+	case f.driveFilesKind == "folders":
+		f.lastListing = f.drive.Folders(parent)
+	case f.driveFilesKind == "photos":
+		f.lastListing = f.drive.Photos(parent)
+	case f.driveFilesKind == "all":
+		f.lastListing = append(f.drive.Folders(parent), f.drive.Photos(parent)...)
+	}
+	if parent.Id == "root" && f.driveFilesKind != "photos" {
+		f.lastListing = append(f.lastListing, sharedDrivesFolder, sharedWithMeFolder)
+	}
+	if len(f.lastListing) == 0 {
+		fmt.Println("no", f.driveFilesKind, "found")
 		return
 	}
-	if f.driveFilesKind == "photos" {
-		f.lastListing = f.drive.Photos(f.driveStack.Top().Id)
-		for _, each := range f.lastListing {
-			found = true
-			fmt.Println(each.Name)
-		}
-		if !found {
-			fmt.Println("no photos found")
+	if opts.SortBy != "" || opts.Reverse {
+		if err := sortListing(f.lastListing, opts.SortBy, opts.Reverse); err != nil {
+			fmt.Println(err)
 		}
+	}
+	if opts.Long {
+		f.printTable(f.lastListing)
 		return
 	}
+	for _, each := range f.lastListing {
+		fmt.Println(displayName(each))
+	}
 }
 
-func (f *Finder) search(fileName string) {
-	if f.driveFilesKind == "folders" {
-		fmt.Println("cannot copy folders")
+// displayName returns the name with a trailing slash for folders.
+func displayName(file *drive.File) string {
+	if isFolder(file) {
+		return file.Name + "/"
+	}
+	return file.Name
+}
+
+func isFolder(file *drive.File) bool {
+	return file.MimeType == folderMimeType
+}
+
+// cd changes to a folder given by a relative or absolute path, or to the previous folder with "-".
+func (f *Finder) cd(dir string) {
+	if dir == "-" {
+		if f.previousStack == nil {
+			fmt.Println("no previous folder")
+			return
+		}
+		f.driveStack, f.previousStack = f.previousStack, f.driveStack
+		f.ls()
 		return
 	}
+	stack, err := f.resolve(dir)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	f.previousStack = f.driveStack
+	f.driveStack = stack
+	f.ls()
+}
+
+// resolve returns the folder stack for the path, resolving each component against its parent.
+func (f *Finder) resolve(dir string) (*Stack[*drive.File], error) {
+	stack := f.driveStack.Clone()
+	absolute := strings.HasPrefix(dir, "/")
+	if absolute {
+		stack = new(Stack[*drive.File])
+		stack.Push(f.driveStack.Bottom())
+	}
+	segments := strings.Split(dir, "/")
+	for i, each := range segments {
+		switch each {
+		case "", ".":
+			continue
+		case "..":
+			// keep root
+			if stack.Size() > 1 {
+				stack.Pop()
+			}
+			continue
+		}
+		children := f.childFolders(stack.Top(), each)
+		if len(children) == 0 && (i == 0 || absolute && i == 1) {
+			// not in this folder, try the computers, which are outside My Drive ; their path starts at the root
+			if computers := f.drive.ComputerFolders(each); len(computers) > 0 {
+				children = computers
+				stack = new(Stack[*drive.File])
+				stack.Push(f.driveStack.Bottom())
+			}
+		}
+		switch len(children) {
+		case 0:
+			return nil, fmt.Errorf("%s: no such folder in %s", each, Path(stack))
+		case 1:
+			stack.Push(children[0])
+		default:
+			return nil, fmt.Errorf("%s: ambiguous, there are %d folders with that name in %s", each, len(children), Path(stack))
+		}
+	}
+	return stack, nil
+}
+
+// childFolders returns the folders with the name in the parent, which can be virtual.
+func (f *Finder) childFolders(parent *drive.File, name string) (list []*drive.File) {
+	switch parent.Id {
+	case "root":
+		if name == sharedDrivesFolder.Name {
+			return append(list, sharedDrivesFolder)
+		}
+		if name == sharedWithMeFolder.Name {
+			return append(list, sharedWithMeFolder)
+		}
+	case sharedDrivesID:
+		if !f.authorize(Operation_Drives) {
+			return nil
+		}
+		for _, each := range f.drive.SharedDrives() {
+			if each.Name == name {
+				list = append(list, each)
+			}
+		}
+		return list
+	}
+	return f.drive.ChildFolders(parent, name)
+}
+
+// pwd prints the path of the current folder.
+func (f *Finder) pwd() {
+	fmt.Println(Path(f.driveStack))
+}
+
+func (f *Finder) search(fileName string) {
 	var found *drive.File
 	for _, each := range f.lastListing {
 		if each.OriginalFilename == fileName || each.Name == fileName {
@@ -51,17 +180,26 @@ func (f *Finder) search(fileName string) {
 		fmt.Println(fileName, " no such file (did you run ls?)")
 		return
 	}
+	if isFolder(found) {
+		fmt.Println(fileName, " is a folder")
+		return
+	}
 	// fmt.Println("mod me", found.ModifiedByMeTime)
 	// fmt.Println("crea", found.CreatedTime)
 	// fmt.Println("shar", found.SharedWithMeTime)
 	// fmt.Println("mod", found.ModifiedTime)
-	var searchTime time.Time
-	if found.ModifiedByMe {
+	searchTime, ok := takenTime(found)
+	if !ok && found.ModifiedByMe {
 		searchTime, _ = time.Parse(time.RFC3339, found.ModifiedByMeTime)
-	} else {
+	} else if !ok {
 		searchTime, _ = time.Parse(time.RFC3339, found.ModifiedTime)
 	}
-	mediaItem, ok := f.photos.Search(fileName, MediaType_Photo, searchTime)
+	mediaItem, ok, err := f.photos.Search(fileName, MediaType_Photo, searchTime)
+	if err != nil {
+		fmt.Println("unable to search Google Photos:", err)
+		return
+	}
+	f.rememberOnPhotos(found, mediaItem.ProductURL)
 	if ok {
 		fmt.Println("found copy on Google Photos: ", mediaItem.ProductURL)
 	} else {
@@ -69,95 +207,371 @@ func (f *Finder) search(fileName string) {
 	}
 }
 
-func (f *Finder) matches(entry string) (list []string) {
-	if f.driveFilesKind == "folders" {
-		fmt.Println("cannot match on folders")
-		return list
+// selectFiles returns the media files that match the pattern and the filter ; folders are never selected.
+// Without glob characters and not in regex mode, the pattern must be the name or original filename.
+// The files come from the last listing unless a filter is given or the selection is recursive.
+func (f *Finder) selectFiles(pattern string, opts TransferOptions) (list []*drive.File, ok bool) {
+	candidates := f.lastListing
+	if opts.Recursive || !opts.Filter.IsEmpty() {
+		candidates = f.walk(f.driveStack.Top(), opts)
 	}
-	if strings.Contains(entry, "*") {
-		entry = strings.Replace(entry, "*", ".*", -1)
-		for _, each := range f.lastListing {
-			if m, _ := regexp.MatchString(entry, each.Name); m {
-				list = append(list, each.Name)
+	// an exact name wins, even if it has glob meta characters such as "IMG [1].jpg"
+	for _, each := range candidates {
+		if each.OriginalFilename == pattern || each.Name == pattern {
+			if isFolder(each) {
+				fmt.Println(pattern, " is a folder, skipped")
+				return list, false
+			}
+			if !opts.Filter.Match(each) {
+				fmt.Println(pattern, " does not match the filter, skipped")
+				return list, false
 			}
+			return append(list, each), true
+		}
+	}
+	if !opts.Regex && !IsPattern(pattern) {
+		fmt.Println(pattern, " no such file (did you run ls?)")
+		return list, false
+	}
+	m, err := NewMatcher(pattern, opts.Regex, opts.IgnoreCase)
+	if err != nil {
+		fmt.Println(err)
+		return list, false
+	}
+	for _, each := range candidates {
+		if !isFolder(each) && m.Match(each.Name) && opts.Filter.Match(each) {
+			list = append(list, each)
 		}
-	} else {
-		list = append(list, entry)
+	}
+	if len(list) == 0 {
+		fmt.Println(pattern, " matches no files (did you run ls?)")
+	}
+	return list, true
+}
+
+// walk returns the photos in the folder, and in all its subfolders if recursive, with the filter pushed down to Drive.
+func (f *Finder) walk(folder *drive.File, opts TransferOptions) []*drive.File {
+	return f.walkPath(folder, Path(f.driveStack), opts)
+}
+
+func (f *Finder) walkPath(folder *drive.File, path string, opts TransferOptions) []*drive.File {
+	list := f.drive.PhotosMatching(folder, opts.Filter)
+	if f.folderPaths == nil {
+		f.folderPaths = map[string]string{}
+	}
+	for _, each := range list {
+		f.folderPaths[each.Id] = path
+	}
+	if !opts.Recursive {
+		return list
+	}
+	for _, each := range f.drive.Folders(folder) {
+		list = append(list, f.walkPath(each, strings.TrimSuffix(path, "/")+"/"+each.Name, opts)...)
 	}
 	return list
 }
-func (f *Finder) rm(fileName string) bool {
-	if f.driveFilesKind == "folders" {
-		fmt.Println("cannot copy folders")
+
+func (f *Finder) rm(pattern string, opts TransferOptions) bool {
+	files, ok := f.selectFiles(pattern, opts)
+	if !ok {
 		return false
 	}
-	if fileName == "*" {
-		for _, each := range f.lastListing {
-			if !f.rm(each.Name) {
-				return false
-			}
+	failed := 0
+	for _, each := range files {
+		if !f.rmFile(each, opts) {
+			failed++
 		}
-		return true
 	}
-	var found *drive.File
-	for _, each := range f.lastListing {
-		if each.OriginalFilename == fileName || each.Name == fileName {
-			found = each
-			break
+	printFailed(failed, len(files), "deleted")
+	return failed == 0
+}
+
+func (f *Finder) rmFile(found *drive.File, opts TransferOptions) bool {
+	rec := f.newRecord(found)
+	ok := f.deleteFile(found, opts, &rec)
+	f.record(rec)
+	return ok
+}
+
+// deleteFile deletes the file from Drive and sets the outcome in the record.
+// After a copy by mv, the record keeps the outcome of the copy: moved if deleted, or else the reason why not.
+func (f *Finder) deleteFile(found *drive.File, opts TransferOptions, rec *TransferRecord) bool {
+	copied := rec.Action
+	if c := found.Capabilities; c != nil && (!c.CanTrash || !c.CanDelete) {
+		fmt.Println(found.Name, " cannot be deleted by you (not the owner?), skipped")
+		if copied != "" {
+			rec.set(copied, errors.New("not deleted from Drive: not allowed"))
+		} else {
+			rec.set(Action_Failed, errors.New("not allowed to delete"))
 		}
+		return false
 	}
-	if found == nil {
-		fmt.Println(fileName, " no such file (did you run ls?)")
+	if opts.DryRun {
+		fmt.Println("would delete", found.Name)
+		return true
+	}
+	if err := f.drive.Delete(found); err != nil {
+		fmt.Println(err)
+		if copied != "" {
+			rec.set(copied, fmt.Errorf("not deleted from Drive: %v", err))
+		} else {
+			rec.set(Action_Failed, err)
+		}
 		return false
 	}
-	if f.drive.Delete(found) {
-		fmt.Println("... done")
+	fmt.Println("... done")
+	switch copied {
+	case Action_Uploaded:
+		rec.set(Action_Moved, nil)
+	case Action_SkippedDuplicate:
+		rec.set(Action_DeletedDuplicate, nil)
+	default:
+		rec.set(Action_Deleted, nil)
 	}
 	return true
 }
 
-func (f *Finder) cp(fileName string) bool {
-	if f.driveFilesKind == "folders" {
-		fmt.Println("cannot copy folders")
+// fixCaptureTime writes a capture time into the downloaded content if it has none.
+// The original on Drive is never changed.
+func fixCaptureTime(file *drive.File, data []byte) []byte {
+	if file.ImageMediaMetadata != nil && file.ImageMediaMetadata.Time != "" {
+		return data
+	}
+	when, ok := captureTime(file)
+	if !ok {
+		return data
+	}
+	fixed, ok := setCaptureTime(data, file.MimeType, when)
+	if ok {
+		fmt.Println("set capture time of", file.Name, "to", when.Format("2006-01-02 15:04:05"))
+	}
+	return fixed
+}
+
+// convert returns the content of the file converted to the target, with the capture time that the conversion may have dropped.
+func (f *Finder) convert(file, target *drive.File, c Converter, data []byte) ([]byte, error) {
+	fmt.Println("converting", file.Name, "to", target.Name)
+	converted, err := c.Convert(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert %s: %v", file.Name, err)
+	}
+	target.Size = int64(len(converted))
+	if err := validateUpload(target); err != nil {
+		return nil, fmt.Errorf("converted %s: %v", target.Name, err)
+	}
+	if when, ok := takenTime(file); ok {
+		if fixed, ok := setCaptureTime(converted, target.MimeType, when); ok {
+			converted = fixed
+		}
+	}
+	return converted, nil
+}
+
+// newRecord starts the report record of the file.
+func (f *Finder) newRecord(file *drive.File) TransferRecord {
+	return TransferRecord{
+		DriveID: file.Id,
+		Name:    file.Name,
+		Path:    f.filePath(file),
+		started: time.Now(),
+	}
+}
+
+// set completes the record with the outcome.
+func (rec *TransferRecord) set(action string, err error) {
+	rec.Action = action
+	rec.DurationMs = time.Since(rec.started).Milliseconds()
+	rec.Error = ""
+	rec.err = err
+	if err != nil {
+		rec.Error = err.Error()
+	}
+}
+
+// record writes the record to the report, if any, unless it has no outcome (e.g. in a dry run).
+func (f *Finder) record(rec TransferRecord) {
+	if rec.Action == "" {
+		return
+	}
+	f.report.Write(rec)
+}
+
+// filePath returns the Drive path of the folder of the file.
+func (f *Finder) filePath(file *drive.File) string {
+	if path, ok := f.folderPaths[file.Id]; ok {
+		return path
+	}
+	return Path(f.driveStack)
+}
+
+// owner changes which files are listed in My Drive: mine, shared (by others) or any.
+func (f *Finder) owner(filter string) {
+	switch filter {
+	case Owner_Mine, Owner_Shared, Owner_Any:
+		f.drive.ownerFilter = filter
+		f.ls()
+	default:
+		fmt.Printf("unknown owner filter %q, use mine, shared or any\n", filter)
+	}
+}
+
+func (f *Finder) mv(pattern string, opts TransferOptions) {
+	files, _ := f.selectFiles(pattern, opts)
+	f.startProgress(files, opts)
+	defer f.stopProgress()
+	failed := 0
+	for _, each := range files {
+		// a file that was not created on Google Photos is kept on Drive
+		// one record for both the copy and the delete
+		rec := f.newRecord(each)
+		if !f.copyFile(each, opts, &rec) || !f.deleteFile(each, opts, &rec) {
+			failed++
+		}
+		f.record(rec)
+	}
+	printFailed(failed, len(files), "moved")
+}
+
+func (f *Finder) cp(pattern string, opts TransferOptions) bool {
+	files, ok := f.selectFiles(pattern, opts)
+	if !ok {
 		return false
 	}
-	if fileName == "*" {
-		for _, each := range f.lastListing {
-			if !f.cp(each.Name) {
-				return false
-			}
+	f.startProgress(files, opts)
+	defer f.stopProgress()
+	failed := 0
+	for _, each := range files {
+		if !f.cpFile(each, opts) {
+			failed++
 		}
-		return true
 	}
-	var found *drive.File
-	for _, each := range f.lastListing {
-		if each.OriginalFilename == fileName || each.Name == fileName {
-			found = each
-			break
+	printFailed(failed, len(files), "copied")
+	return failed == 0
+}
+
+// printFailed reports how many of the files were not transferred.
+func printFailed(failed, total int, verb string) {
+	if failed > 0 {
+		fmt.Printf("%d of %d files not %s\n", failed, total, verb)
+	}
+}
+
+// startProgress starts reporting the progress of transferring the files.
+func (f *Finder) startProgress(files []*drive.File, opts TransferOptions) {
+	if opts.DryRun {
+		return
+	}
+	total := int64(0)
+	for _, each := range files {
+		total += each.Size
+	}
+	f.progress = NewProgress(len(files), total)
+}
+
+func (f *Finder) stopProgress() {
+	f.progress = nil
+}
+
+func (f *Finder) cpFile(found *drive.File, opts TransferOptions) bool {
+	rec := f.newRecord(found)
+	ok := f.copyFile(found, opts, &rec)
+	f.record(rec)
+	return ok
+}
+
+// copyFile copies the file to Google Photos unless it is there already, and sets the outcome in the record.
+func (f *Finder) copyFile(found *drive.File, opts TransferOptions, rec *TransferRecord) bool {
+	defer f.progress.FileDone(found.Size)
+	searchTime, ok := takenTime(found)
+	if !ok {
+		var err error
+		searchTime, err = time.Parse(time.RFC3339, found.ModifiedTime)
+		if err != nil {
+			fmt.Println("cannot parse created time", found.ModifiedTime)
+			if !opts.DryRun {
+				rec.set(Action_Failed, fmt.Errorf("cannot parse modified time %q", found.ModifiedTime))
+			}
+			return false
 		}
 	}
-	if found == nil {
-		fmt.Println(fileName, " no such file (did you run ls?)")
+	// target is what is uploaded: the file itself or its conversion
+	target := found
+	converter, convert := f.converterFor(found)
+	if convert {
+		target = convertedFile(found, converter)
+	}
+	if err := validateUpload(target); err != nil {
+		if opts.DryRun {
+			fmt.Println("would skip", found.Name+":", err)
+			return false
+		}
+		fmt.Println("skipped", found.Name+":", err)
+		rec.set(Action_SkippedInvalid, err)
 		return false
 	}
-	searchTime, err := time.Parse(time.RFC3339, found.ModifiedTime)
+	kind, _ := mediaType(target)
+	mediaItem, ok, err := f.photos.Search(target.Name, kind, searchTime)
 	if err != nil {
-		fmt.Println("cannot parse created time", found.ModifiedTime)
+		// not knowing whether it is there already, a copy could be a duplicate
+		fmt.Println("unable to search Google Photos:", err)
+		if !opts.DryRun {
+			rec.set(Action_Failed, err)
+		}
 		return false
 	}
-	mediaItem, ok := f.photos.Search(fileName, MediaType_Photo, searchTime)
+	f.rememberOnPhotos(found, mediaItem.ProductURL)
 	if ok {
 		fmt.Println("found copy on Google Photos, no copy needed: ", mediaItem.ProductURL)
+		if !opts.DryRun {
+			rec.MediaItemID, rec.ProductURL = mediaItem.ID, mediaItem.ProductURL
+			rec.set(Action_SkippedDuplicate, nil)
+		}
 		return true
 	}
-	data, ok := f.drive.Download(found)
-	if !ok {
+	if opts.DryRun {
+		if convert {
+			fmt.Println("would convert", found.Name, "to", target.Name, "and copy")
+		} else {
+			fmt.Println("would copy", found.Name)
+		}
+		return true
+	}
+	data, err := f.drive.Download(found, f.progress)
+	if err != nil {
+		fmt.Println(err)
+		rec.set(Action_Failed, err)
 		return false
 	}
-	fmt.Println("... done")
-	if !f.photos.Upload(found, data) {
+	rec.Bytes = int64(len(data))
+	if f.progress == nil {
+		fmt.Println("... done")
+	}
+	if convert {
+		if data, err = f.convert(found, target, converter, data); err != nil {
+			fmt.Println("error:", err)
+			rec.set(Action_Failed, err)
+			return false
+		}
+	}
+	if *fixDates {
+		data = fixCaptureTime(target, data)
+	}
+	created, err := f.photos.Upload(target, data, f.describe(found, convert), f.progress)
+	if err != nil {
+		var rejected *UploadError
+		if errors.As(err, &rejected) && rejected.Status.Temporary() {
+			fmt.Println("error:", err, "; try again later")
+		} else {
+			fmt.Println("error:", err)
+		}
+		rec.set(Action_Failed, err)
 		return false
 	}
-	fmt.Println("... done")
+	f.rememberOnPhotos(found, created.ProductURL)
+	rec.MediaItemID, rec.ProductURL = created.ID, created.ProductURL
+	rec.set(Action_Uploaded, nil)
+	if f.progress == nil {
+		fmt.Println("... done")
+	}
 	return true
 }
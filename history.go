@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/peterh/liner"
+)
+
+// historyFile returns the location of the command history, shared by all sessions.
+func historyFile() string {
+	return filepath.Join(configDir(), "history")
+}
+
+func readHistory(line *liner.State) {
+	f, err := os.Open(historyFile())
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	line.ReadHistory(f)
+}
+
+func writeHistory(line *liner.State) {
+	if err := os.MkdirAll(configDir(), 0700); err != nil {
+		fmt.Println("unable to save history:", err)
+		return
+	}
+	f, err := os.OpenFile(historyFile(), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		fmt.Println("unable to save history:", err)
+		return
+	}
+	defer f.Close()
+	line.WriteHistory(f)
+}
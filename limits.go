@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// Limits of Google Photos, see https://support.google.com/googlephotos/answer/6193313
+const (
+	maxPhotoSize = 200 << 20 // 200 MB
+	maxVideoSize = 20 << 30  // 20 GB
+)
+
+// supportedMimeTypes are the formats that Google Photos accepts, by media type.
+var supportedMimeTypes = map[string]string{
+	"image/avif":               MediaType_Photo,
+	"image/bmp":                MediaType_Photo,
+	"image/gif":                MediaType_Photo,
+	"image/heic":               MediaType_Photo,
+	"image/heif":               MediaType_Photo,
+	"image/vnd.microsoft.icon": MediaType_Photo,
+	"image/x-icon":             MediaType_Photo,
+	"image/jpeg":               MediaType_Photo,
+	"image/png":                MediaType_Photo,
+	"image/tiff":               MediaType_Photo,
+	"image/webp":               MediaType_Photo,
+	"image/x-adobe-dng":        MediaType_Photo,
+	"image/x-canon-cr2":        MediaType_Photo,
+	"image/x-canon-cr3":        MediaType_Photo,
+	"image/x-nikon-nef":        MediaType_Photo,
+	"image/x-sony-arw":         MediaType_Photo,
+	"image/x-olympus-orf":      MediaType_Photo,
+	"image/x-panasonic-rw2":    MediaType_Photo,
+	"image/x-fuji-raf":         MediaType_Photo,
+	"video/3gpp":               MediaType_Video,
+	"video/3gpp2":              MediaType_Video,
+	"video/x-ms-asf":           MediaType_Video,
+	"video/x-msvideo":          MediaType_Video,
+	"video/avi":                MediaType_Video,
+	"video/divx":               MediaType_Video,
+	"video/mp2t":               MediaType_Video,
+	"video/x-m4v":              MediaType_Video,
+	"video/x-matroska":         MediaType_Video,
+	"video/quicktime":          MediaType_Video,
+	"video/mp4":                MediaType_Video,
+	"video/mpeg":               MediaType_Video,
+	"video/x-ms-wmv":           MediaType_Video,
+}
+
+// mediaType returns MediaType_Photo or MediaType_Video for a format that Google Photos accepts.
+func mediaType(file *drive.File) (string, bool) {
+	mimeType := file.MimeType
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = mime.TypeByExtension(strings.ToLower(filepath.Ext(file.Name)))
+	}
+	kind, ok := supportedMimeTypes[mimeType]
+	return kind, ok
+}
+
+// validateUpload returns why Google Photos would reject the file, using only its Drive metadata.
+func validateUpload(file *drive.File) error {
+	kind, ok := mediaType(file)
+	if !ok {
+		return fmt.Errorf("format %s is not supported by Google Photos", file.MimeType)
+	}
+	max := int64(maxPhotoSize)
+	if kind == MediaType_Video {
+		max = maxVideoSize
+	}
+	if file.Size > max {
+		return fmt.Errorf("size %s exceeds the limit of %s for a %s", humanSize(file.Size), humanSize(max), strings.ToLower(kind))
+	}
+	return nil
+}
+
+// check lists the files in the current folder that would not be copied to Google Photos.
+func (f *Finder) check() {
+	files := f.drive.Files(f.driveStack.Top())
+	problems := 0
+	for _, each := range files {
+		err := validateUpload(each)
+		if err == nil {
+			continue
+		}
+		if c, ok := f.converterFor(each); ok {
+			mimeType, _ := c.Target()
+			fmt.Printf("%s: %v ; will be converted to %s\n", each.Name, err, mimeType)
+			continue
+		}
+		fmt.Printf("%s: %v\n", each.Name, err)
+		problems++
+	}
+	fmt.Printf("%d of %d files would not be copied\n", problems, len(files))
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	SortBy_Name = "name"
+	SortBy_Date = "date"
+	SortBy_Size = "size"
+)
+
+// sortListing sorts the files in place by name, modification date or size.
+func sortListing(list []*drive.File, by string, reverse bool) error {
+	var less func(a, b *drive.File) bool
+	switch by {
+	case SortBy_Name, "":
+		less = func(a, b *drive.File) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) }
+	case SortBy_Date:
+		less = func(a, b *drive.File) bool { return a.ModifiedTime < b.ModifiedTime }
+	case SortBy_Size:
+		less = func(a, b *drive.File) bool { return a.Size < b.Size }
+	default:
+		return fmt.Errorf("cannot sort by %q, use name, date or size", by)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if reverse {
+			return less(list[j], list[i])
+		}
+		return less(list[i], list[j])
+	})
+	return nil
+}
+
+// printTable prints the files with size, dates, capture time, mime type and whether they are on Google Photos.
+func (f *Finder) printTable(list []*drive.File) {
+	rows := [][]string{{"SIZE", "CREATED", "MODIFIED", "TAKEN", "TYPE", "PHOTOS", "NAME"}}
+	for _, each := range list {
+		taken := ""
+		if each.ImageMediaMetadata != nil {
+			taken = each.ImageMediaMetadata.Time
+		}
+		rows = append(rows, []string{
+			humanSize(each.Size),
+			shortTime(each.CreatedTime),
+			shortTime(each.ModifiedTime),
+			taken,
+			each.MimeType,
+			f.photosStatus(each),
+			displayName(each),
+		})
+	}
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			if w := runewidth.StringWidth(cell); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	for _, row := range rows {
+		b := new(strings.Builder)
+		for i, cell := range row {
+			if i == len(row)-1 {
+				b.WriteString(cell)
+				break
+			}
+			if i == 0 {
+				// right align sizes
+				b.WriteString(runewidth.FillLeft(cell, widths[i]))
+			} else {
+				b.WriteString(runewidth.FillRight(cell, widths[i]))
+			}
+			b.WriteString("  ")
+		}
+		fmt.Println(b.String())
+	}
+}
+
+// photosStatus returns whether the file was found on Google Photos by an earlier search or copy.
+func (f *Finder) photosStatus(file *drive.File) string {
+	if isFolder(file) {
+		return ""
+	}
+	url, ok := f.photosCache[file.Id]
+	if !ok {
+		return "?"
+	}
+	if url == "" {
+		return "no"
+	}
+	return "yes"
+}
+
+// rememberOnPhotos caches the result of a search or upload for the file.
+func (f *Finder) rememberOnPhotos(file *drive.File, productURL string) {
+	if f.photosCache == nil {
+		f.photosCache = map[string]string{}
+	}
+	f.photosCache[file.Id] = productURL
+}
+
+// shortTime formats an RFC3339 time as local date and time without seconds.
+func shortTime(rfc3339 string) string {
+	t, err := time.Parse(time.RFC3339, rfc3339)
+	if err != nil {
+		return rfc3339
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/peterh/liner"
 	"golang.org/x/oauth2/google"
@@ -16,46 +17,93 @@ import (
 )
 
 var owner = flag.String("email", "", "Google email address")
+var ownerFilter = flag.String("owner", Owner_Mine, "list files in My Drive owned by: mine, shared (by others) or any")
+var authMode = flag.String("auth-mode", AuthMode_Browser, "authorization flow: browser or manual (copy-paste)")
+var credentialsFile = flag.String("credentials", "", "OAuth2 client credentials file (default credentials.json in the config directory)")
+var tokenFile = flag.String("token", "", "OAuth2 token file (default token.json in the config directory)")
+var tokenStoreKind = flag.String("token-store", TokenStore_File, "where to keep the token: file, encrypted or keyring")
+var driveProfile = flag.String("drive-profile", defaultProfile, "profile (account) to read from Google Drive")
+var photosProfile = flag.String("photos-profile", defaultProfile, "profile (account) to write to Google Photos")
+var watchFolders = flag.String("watch", "", "comma separated Drive folder paths to watch ; new media is copied to Google Photos")
+var watchInterval = flag.Duration("watch-interval", time.Minute, "time between polls of the Drive changes in watch mode")
+var fixDates = flag.Bool("fix-dates", false, "before upload, write the capture time into media without one (from the filename or Drive), so it lands on the right date in Google Photos")
+var convertMedia = flag.Bool("convert", false, "convert media that Google Photos does not accept: PNG, TIFF and BMP to JPEG, and with ffmpeg or ImageMagick (if installed) other videos and images")
+var descriptionTemplate = flag.String("description", defaultDescriptionTemplate, "text/template for the description of uploaded media items, with .File (Drive file), .Folder, .Owner and .OriginalFilename")
+var noDescription = flag.Bool("no-description", false, "upload media items without description")
+var reportFile = flag.String("report", "", "append a record per transferred file to this CSV (.csv) or JSON lines file")
+var allowDelete = flag.Bool("allow-delete", false, "enable the rm and mv commands, which need write access to Google Drive")
 
-var cmds = ":q :p :f cd ls cp rm mv ff"
+func init() {
+	flag.Var(filenameDateFlag{}, "filename-date", "regular expression with groups (?P<year>..)(?P<month>..)(?P<day>..) and optionally hour, minute and second to find the capture date in filenames (repeatable)")
+}
 
 func main() {
 	flag.Parse()
-	fmt.Println("drive2photos --- [" + cmds + "]")
+	fmt.Println("drive2photos --- " + commands.Banner())
 
 	if *owner == "" {
 		fmt.Println("email flag is required")
 		return
 	}
+	if *authMode != AuthMode_Browser && *authMode != AuthMode_Manual {
+		fmt.Printf("unknown auth mode %q, use browser or manual\n", *authMode)
+		return
+	}
 
 	ctx := context.Background()
-	b, err := os.ReadFile("credentials.json")
+	b, err := os.ReadFile(configFile(*credentialsFile, "credentials.json"))
 	if err != nil {
 		log.Fatalf("Unable to read client secret file: %v", err)
 	}
 
-	// If modifying these scopes, delete your previously saved token.json.
-	// https://developers.google.com/photos/library/guides/authorization
-	config, err := google.ConfigFromJSON(b,
-		drive.DrivePhotosReadonlyScope,
-		drive.DriveScope,
-		"https://www.googleapis.com/auth/drive.readonly.metadata",
-		"https://www.googleapis.com/auth/photoslibrary.readonly",
-		"https://www.googleapis.com/auth/photoslibrary.appendonly",
-		"https://www.googleapis.com/auth/photoslibrary.edit.appcreateddata")
+	// Scopes are requested per operation, see scopes.go.
+	config, err := google.ConfigFromJSON(b)
 	if err != nil {
 		log.Fatalf("Unable to parse client secret file to config: %v", err)
 	}
-	client := getClient(config)
+	driveAuth, photosAuth, err := profileAuthorizers(config, *driveProfile, *photosProfile)
+	if err != nil {
+		log.Fatalf("Unable to create token store: %v", err)
+	}
 
-	srv, err := drive.NewService(ctx, option.WithHTTPClient(client))
+	srv, err := drive.NewService(ctx, option.WithHTTPClient(driveAuth.Client()))
 	if err != nil {
 		log.Fatalf("Unable to retrieve Drive client: %v", err)
 	}
-	d := DriveService{service: srv, owner: *owner, client: new(http.Client)}
-	s := PhotosService{client: client}
-	f := Finder{drive: d, photos: s, driveStack: new(Stack[*drive.File]), driveFilesKind: "folders"}
+	d := DriveService{service: srv, owner: *owner, ownerFilter: *ownerFilter, client: new(http.Client)}
+	s := PhotosService{client: photosAuth.Client()}
+	f := Finder{drive: d, photos: s, driveAuth: driveAuth, photosAuth: photosAuth, driveStack: new(Stack[*drive.File]), driveFilesKind: "folders"}
 	f.driveStack.Push(&drive.File{Id: "root", Name: "/"})
+	if !*noDescription {
+		f.describer, err = NewDescriber(*descriptionTemplate)
+		if err != nil {
+			log.Fatalf("Unable to use description: %v", err)
+		}
+	}
+	if *convertMedia {
+		f.converters = defaultConverters()
+	}
+	if *reportFile != "" {
+		f.report, err = OpenReport(*reportFile)
+		if err != nil {
+			log.Fatalf("Unable to open report: %v", err)
+		}
+		defer f.report.Close()
+	}
+	if *watchFolders != "" {
+		if !f.authorize(Operation_Copy) {
+			return
+		}
+		w, err := newWatcher(&f, strings.Split(*watchFolders, ","), *watchInterval)
+		if err != nil {
+			log.Fatalf("Unable to watch: %v", err)
+		}
+		w.Run()
+		return
+	}
+	if !f.authorize(Operation_Browse) {
+		return
+	}
 	f.ls()
 	f.repl()
 }
@@ -63,116 +111,39 @@ func main() {
 type Finder struct {
 	drive          DriveService
 	driveStack     *Stack[*drive.File]
+	previousStack  *Stack[*drive.File]
 	lastListing    []*drive.File
+	photosCache    map[string]string // Drive file id -> product URL on Google Photos, empty if not found
+	progress       *Progress         // of the current cp or mv, nil if none
+	report         *Report           // nil if no report is written
+	describer      *Describer        // nil if no descriptions
+	converters     []Converter       // empty if no conversion
+	folderPaths    map[string]string // Drive file id -> path of its folder, found by a recursive walk
 	photos         PhotosService
+	driveAuth      *Authorizer
+	photosAuth     *Authorizer
 	driveFilesKind string
+	quit           bool
 }
 
 func (f *Finder) repl() {
 	line := liner.NewLiner()
 	defer line.Close()
 	line.SetCtrlCAborts(true)
-	for {
+	line.SetWordCompleter(commands.WordCompleter(f))
+	line.SetTabCompletionStyle(liner.TabPrints)
+	readHistory(line)
+	defer writeHistory(line)
+	for !f.quit {
 		entry, err := line.Prompt(fmt.Sprintf("<%s::%s> ", f.driveFilesKind, Path(f.driveStack)))
 		if err != nil {
 			break
 		}
-		if entry == ":q" {
-			break
-		}
-		if entry == ":f" {
-			f.driveFilesKind = "folders"
-			f.ls()
-			continue
-		}
-		if entry == ":p" {
-			f.driveFilesKind = "photos"
-			f.ls()
-			continue
-		}
-		if entry == "ls" {
-			f.ls()
-			continue
-		}
-		if strings.HasPrefix(entry, "ff") {
-			obj := parameterFromEntry(entry)
-			if obj != "" {
-				f.search(obj)
-			}
-			continue
+		if strings.TrimSpace(entry) != "" {
+			line.AppendHistory(entry)
 		}
-		if strings.HasPrefix(entry, "cp") {
-			obj := parameterFromEntry(entry)
-			if obj != "" {
-				f.cp(obj)
-			}
-			continue
-		}
-		if strings.HasPrefix(entry, "rm") {
-			obj := parameterFromEntry(entry)
-			if obj != "" {
-				f.rm(obj)
-			}
-			f.ls()
-			continue
-		}
-		if strings.HasPrefix(entry, "mv") {
-			obj := parameterFromEntry(entry)
-			if obj != "" {
-				for _, each := range f.matches(obj) {
-					if f.cp(each) {
-						f.rm(each)
-					}
-				}
-			}
-			f.ls()
-			continue
-		}
-		if strings.HasPrefix(entry, "cd") {
-			dir := parameterFromEntry(entry)
-			if dir != "" {
-				if dir == ".." {
-					// keep root
-					if f.driveStack.Size() > 1 {
-						f.driveStack.Pop()
-					}
-					f.ls()
-					continue
-				}
-				var found *drive.File
-				for _, each := range f.lastListing {
-					if each.Name == dir {
-						found = each
-						break
-					}
-				}
-				if found == nil {
-					found := f.drive.FolderByName(dir)
-					if found == nil {
-						fmt.Println(dir, " no such folder (did you run ls?)")
-					} else {
-						f.driveStack.Push(found)
-					}
-				} else {
-					f.driveStack.Push(found)
-				}
-			}
-			f.ls()
-			continue
-		} else {
-			// fallback
-			fmt.Println("unknown command, try " + cmds)
-		}
-		line.AppendHistory(entry)
-	}
-}
-
-func parameterFromEntry(entry string) string {
-	space := strings.Index(entry, " ")
-	if space == -1 {
-		return entry
+		commands.Dispatch(f, entry)
 	}
-	return strings.TrimSpace(entry[space+1:])
 }
 
 func Path(s *Stack[*drive.File]) string {
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Matcher selects names using glob patterns (*, ?, [...], always anchored) or a regular expression.
+// Unlike path.Match, * also matches a slash, which is a legal character in Drive names.
+type Matcher struct {
+	regex *regexp.Regexp
+}
+
+// NewMatcher returns a Matcher for the pattern ; it fails if the pattern is malformed.
+func NewMatcher(pattern string, useRegex, ignoreCase bool) (*Matcher, error) {
+	expr := pattern
+	if !useRegex {
+		glob, err := globToRegexp(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		expr = glob
+	}
+	if ignoreCase {
+		expr = "(?i)" + expr
+	}
+	r, err := regexp.Compile(expr)
+	if err != nil {
+		if useRegex {
+			return nil, fmt.Errorf("invalid regular expression %q: %v", pattern, err)
+		}
+		return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+	return &Matcher{regex: r}, nil
+}
+
+// Match returns whether the name matches the whole glob pattern or contains the regular expression.
+func (m *Matcher) Match(name string) bool {
+	return m.regex.MatchString(name)
+}
+
+// IsPattern returns whether the text has glob meta characters.
+func IsPattern(text string) bool {
+	return strings.ContainsAny(text, `*?[\`)
+}
+
+// globToRegexp returns the anchored regular expression for the glob pattern.
+func globToRegexp(pattern string) (string, error) {
+	b := new(strings.Builder)
+	b.WriteString(`(?s)^`)
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '*':
+			b.WriteString(`.*`)
+		case '?':
+			b.WriteString(`.`)
+		case '\\':
+			if i+1 == len(runes) {
+				return "", fmt.Errorf("trailing backslash")
+			}
+			i++
+			b.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case '[':
+			end := i + 1
+			if end < len(runes) && runes[end] == '^' {
+				end++
+			}
+			// a ] right after the opening is part of the set
+			if end < len(runes) && runes[end] == ']' {
+				end++
+			}
+			for end < len(runes) && runes[end] != ']' {
+				if runes[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(runes) {
+				return "", fmt.Errorf("missing ]")
+			}
+			b.WriteString(string(runes[i : end+1]))
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString(`$`)
+	return b.String(), nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestMatcherGlob(t *testing.T) {
+	for _, each := range []struct {
+		pattern    string
+		ignoreCase bool
+		name       string
+		want       bool
+	}{
+		{"*.jpg", false, "IMG_0001.jpg", true},
+		{"*.jpg", false, "IMG_0001.jpg.txt", false},
+		{"*.jpg", false, "a/b.jpg", true},
+		{"IMG_00[1-3]?.jpg", false, "IMG_0024.jpg", true},
+		{"IMG_00[1-3]?.jpg", false, "IMG_0044.jpg", false},
+		{"IMG_00[^1-3]?.jpg", false, "IMG_0044.jpg", true},
+		{"*.JPG", true, "img.jpg", true},
+		{"*.JPG", false, "img.jpg", false},
+		{`IMG \[1\].jpg`, false, "IMG [1].jpg", true},
+		{"a.b", false, "axb", false},
+		{"(1)+.jpg", false, "(1)+.jpg", true},
+	} {
+		m, err := NewMatcher(each.pattern, false, each.ignoreCase)
+		if err != nil {
+			t.Fatalf("%q: %v", each.pattern, err)
+		}
+		if got := m.Match(each.name); got != each.want {
+			t.Errorf("%q matches %q: got %v want %v", each.pattern, each.name, got, each.want)
+		}
+	}
+}
+
+func TestMatcherRegex(t *testing.T) {
+	m, err := NewMatcher(`^IMG_\d+`, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Match("img_123.jpg") {
+		t.Error("expected match ignoring case")
+	}
+	if m.Match("x_IMG_1.jpg") {
+		t.Error("expected no match")
+	}
+}
+
+func TestMatcherInvalid(t *testing.T) {
+	for _, each := range []string{"IMG[1", `IMG\`} {
+		if _, err := NewMatcher(each, false, false); err == nil {
+			t.Errorf("%q: expected error", each)
+		}
+	}
+	if _, err := NewMatcher("(", true, false); err == nil {
+		t.Error("expected error for regex")
+	}
+}
+
+func TestIsPattern(t *testing.T) {
+	if IsPattern("IMG_0001.jpg") {
+		t.Error("plain name is not a pattern")
+	}
+	if !IsPattern("IMG [1].jpg") {
+		t.Error("brackets are a pattern")
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -25,99 +26,65 @@ type PhotosService struct {
 }
 
 // https://developers.google.com/photos/library/guides/upload-media#creating-media-bp
-func (s *PhotosService) Upload(file *drive.File, content []byte) bool {
+func (s *PhotosService) Upload(file *drive.File, content []byte, description string, progress *Progress) (MediaItem, error) {
 	mimeType := file.MimeType
 	if mimeType == "" {
 		mimeType = mime.TypeByExtension(filepath.Ext(file.Name))
 	}
-	fmt.Println("uploading", file.Name, "with", len(content), "bytes created on", file.CreatedTime, "mime", mimeType)
+	if progress == nil {
+		fmt.Println("uploading", file.Name, "with", len(content), "bytes created on", file.CreatedTime, "mime", mimeType)
+	}
 
 	// first upload bytes
-	payloadReader := bytes.NewReader(content)
+	payloadReader := progress.Reader(bytes.NewReader(content))
 	req, err := http.NewRequest("POST", "https://photoslibrary.googleapis.com/v1/uploads", payloadReader)
 	if err != nil {
-		fmt.Println("error:", err)
-		return false
+		return MediaItem{}, err
 	}
+	req.ContentLength = int64(len(content))
 	req.Header.Set("Content-Type", "application/octet-stream")
 	req.Header.Set("X-Goog-Upload-File-Name", file.Name)
 	req.Header.Set("X-Goog-Upload-Protocol", "raw")
 	req.Header.Set("X-Goog-Upload-Content-Type", mimeType)
 
+	progress.Begin("uploading", file.Name, int64(len(content)))
 	resp, err := s.client.Do(req)
+	progress.End()
 	if err != nil {
-		fmt.Println("error:", err)
-		return false
+		return MediaItem{}, err
 	}
 	if resp.StatusCode != http.StatusOK {
-		fmt.Println("error:", resp.Status)
-		return false
-	}
-	if err != nil {
-		fmt.Println("error:", err)
-		return false
+		return MediaItem{}, &HTTPError{Op: "upload", StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 	defer resp.Body.Close()
 	uploadTokenData, err := io.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Println("error:", err)
-		return false
+		return MediaItem{}, err
 	}
 	uploadToken := string(uploadTokenData)
 	if len(uploadToken) == 0 {
-		fmt.Println("error:", "no upload token")
-		return false
+		return MediaItem{}, errors.New("no upload token")
 	}
 
 	// now create media item
-	// payload
-	doc := map[string][]NewMediaItem{}
-	when := file.ModifiedTime
-	if when == "" {
-		when = file.CreatedTime
-	}
-	doc["newMediaItems"] = []NewMediaItem{
+	results, err := s.BatchCreate([]NewMediaItem{
 		{
-			Description: file.Description + "\n" + when,
+			Description: description,
 			SimpleMediaItem: SimpleMediaItem{
 				Filename:    file.Name,
 				UploadToken: uploadToken,
 			},
 		},
-	}
-	body, err := json.Marshal(doc)
+	})
 	if err != nil {
-		fmt.Println("error:", err)
-		return false
-	}
-	// fmt.Println(string(body))
-	req, err = http.NewRequest("POST", "https://photoslibrary.googleapis.com/v1/mediaItems:batchCreate", bytes.NewReader(body))
-	if err != nil {
-		fmt.Println("error:", err)
-		return false
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err = s.client.Do(req)
-	if err != nil {
-		fmt.Println("error:", err)
-		return false
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		fmt.Println("error:", resp.Status)
-		return false
+		return MediaItem{}, err
 	}
-	content, err = io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println("error:", err)
-		return false
+	if len(results) == 0 {
+		return MediaItem{}, &UploadError{Filename: file.Name, Status: Status{Code: Code_Unknown, Message: "no result for media item"}}
 	}
-	resultDoc := NewMediaItemResultsDoc{}
-	err = json.Unmarshal(content, &resultDoc)
-	if err != nil {
-		fmt.Println("error:", err)
-		return false
+	result := results[0]
+	if !result.Status.OK() {
+		return MediaItem{}, &UploadError{Filename: file.Name, Status: result.Status}
 	}
 
 	/**
@@ -164,29 +131,68 @@ func (s *PhotosService) Upload(file *drive.File, content []byte) bool {
 	}
 	fmt.Println("photo stored on timeline at", patchedItem.MediaMetadata.CreationTime)
 	**/
-	fmt.Println("photo stored on timeline at", resultDoc.NewMediaItemResults[0].MediaItem.MediaMetadata.CreationTime)
+	fmt.Println("photo stored on timeline at", result.MediaItem.MediaMetadata.CreationTime)
+	return result.MediaItem, nil
+}
 
-	return true
+// BatchCreate creates media items from upload tokens.
+// Each item has its own result, in the order of the items, because some may be created while others are rejected.
+// https://developers.google.com/photos/library/reference/rest/v1/mediaItems/batchCreate
+func (s *PhotosService) BatchCreate(items []NewMediaItem) ([]NewMediaItemResult, error) {
+	body, err := json.Marshal(map[string][]NewMediaItem{"newMediaItems": items})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", "https://photoslibrary.googleapis.com/v1/mediaItems:batchCreate", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// 207 means that some of the items were not created
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusMultiStatus {
+		return nil, &HTTPError{Op: "create media item", StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	resultDoc := NewMediaItemResultsDoc{}
+	if err := json.NewDecoder(resp.Body).Decode(&resultDoc); err != nil {
+		return nil, err
+	}
+	return resultDoc.ordered(items), nil
 }
 
 type NewMediaItemResultsDoc struct {
-	NewMediaItemResults []struct {
-		UploadToken string `json:"uploadToken"`
-		Status      struct {
-			Message string `json:"message"`
-		} `json:"status"`
-		MediaItem struct {
-			ID            string `json:"id"`
-			ProductURL    string `json:"productUrl"`
-			MimeType      string `json:"mimeType"`
-			MediaMetadata struct {
-				CreationTime time.Time `json:"creationTime"`
-				Width        string    `json:"width"`
-				Height       string    `json:"height"`
-			} `json:"mediaMetadata"`
-			Filename string `json:"filename"`
-		} `json:"mediaItem"`
-	} `json:"newMediaItemResults"`
+	NewMediaItemResults []NewMediaItemResult `json:"newMediaItemResults"`
+}
+
+// ordered returns a result for each item, matched by upload token.
+// Items without a result get the status Code_Unknown.
+func (d NewMediaItemResultsDoc) ordered(items []NewMediaItem) []NewMediaItemResult {
+	byToken := map[string]NewMediaItemResult{}
+	for _, each := range d.NewMediaItemResults {
+		byToken[each.UploadToken] = each
+	}
+	results := make([]NewMediaItemResult, len(items))
+	for i, each := range items {
+		result, ok := byToken[each.SimpleMediaItem.UploadToken]
+		if !ok {
+			result = NewMediaItemResult{
+				UploadToken: each.SimpleMediaItem.UploadToken,
+				Status:      Status{Code: Code_Unknown, Message: "no result for media item"},
+			}
+		}
+		results[i] = result
+	}
+	return results
+}
+
+type NewMediaItemResult struct {
+	UploadToken string    `json:"uploadToken"`
+	Status      Status    `json:"status"`
+	MediaItem   MediaItem `json:"mediaItem"`
 }
 
 type NewMediaItem struct {
@@ -199,7 +205,9 @@ type SimpleMediaItem struct {
 	UploadToken string `json:"uploadToken,omitempty"`
 }
 
-func (s *PhotosService) Search(fileName, mediaType string, searchTime time.Time) (MediaItem, bool) {
+// Search returns the media item with the filename taken on the day of searchTime.
+// Only media items created by this application can be found.
+func (s *PhotosService) Search(fileName, mediaType string, searchTime time.Time) (MediaItem, bool, error) {
 	year := searchTime.Year()
 	month := int(searchTime.Month())
 	day := searchTime.Day()
@@ -235,21 +243,24 @@ func (s *PhotosService) Search(fileName, mediaType string, searchTime time.Time)
 		"application/json",
 		queryReader)
 	if err != nil {
-		return MediaItem{}, false
+		return MediaItem{}, false, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return MediaItem{}, false, &HTTPError{Op: "search", StatusCode: resp.StatusCode, Status: resp.Status}
+	}
 	items := MediaItems{}
 	err = json.NewDecoder(resp.Body).Decode(&items)
 	if err != nil {
-		panic(err)
+		return MediaItem{}, false, err
 	}
 	if len(items.MediaItems) == 0 {
 		log.Println("no matching media items found", fileName, searchTime)
 	}
 	for _, each := range items.MediaItems {
 		if each.Filename == fileName {
-			return each, true
+			return each, true, nil
 		}
 	}
-	return MediaItem{}, false
+	return MediaItem{}, false, nil
 }
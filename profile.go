@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"golang.org/x/oauth2"
+)
+
+const defaultProfile = "default"
+
+// profileTokenStore returns the token store of a named profile.
+// The default profile uses the -token location ; others are kept in the profiles subdirectory of the config directory.
+// In the keyring, the entry has the profile name as account unless -token is given for the default profile.
+func profileTokenStore(profile string) (TokenStore, error) {
+	if profile == "" {
+		profile = defaultProfile
+	}
+	if *tokenStoreKind == TokenStore_Keyring {
+		if profile == defaultProfile && *tokenFile != "" {
+			return newTokenStore(*tokenStoreKind, *tokenFile)
+		}
+		return newTokenStore(*tokenStoreKind, profile)
+	}
+	path := configFile(*tokenFile, "token.json")
+	if profile != defaultProfile {
+		path = filepath.Join(configDir(), "profiles", profile, "token.json")
+	}
+	return newTokenStore(*tokenStoreKind, path)
+}
+
+// profileAuthorizers returns the authorizers for Drive and Photos.
+// If both use the same profile then they share one.
+func profileAuthorizers(config *oauth2.Config, driveProfile, photosProfile string) (driveAuth, photosAuth *Authorizer, err error) {
+	driveAuth, err = profileAuthorizer(config, driveProfile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if photosProfile == driveProfile {
+		driveAuth.purpose = "Google Drive and Photos"
+		return driveAuth, driveAuth, nil
+	}
+	driveAuth.purpose = "Google Drive"
+	photosAuth, err = profileAuthorizer(config, photosProfile)
+	if err != nil {
+		return nil, nil, err
+	}
+	photosAuth.purpose = "Google Photos"
+	return driveAuth, photosAuth, nil
+}
+
+func profileAuthorizer(config *oauth2.Config, profile string) (*Authorizer, error) {
+	store, err := profileTokenStore(profile)
+	if err != nil {
+		return nil, err
+	}
+	// each profile has its own scopes and redirect during authorization
+	profileConfig := *config
+	return newAuthorizer(&profileConfig, store, *authMode, profile), nil
+}
+
+func (a *Authorizer) String() string {
+	return fmt.Sprintf("profile %q", a.profile)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Progress reports bytes, rate and ETA of a batch of transfers.
+// On a terminal it redraws one status line ; otherwise it prints a log line now and then.
+// All methods can be called on a nil Progress.
+type Progress struct {
+	mu         sync.Mutex
+	out        io.Writer
+	tty        bool
+	interval   time.Duration
+	totalFiles int
+	totalBytes int64
+	doneFiles  int
+	doneBytes  int64 // size of the files that are done
+	// transferred counts the bytes of all downloads and uploads ; each file is transferred twice.
+	transferred int64
+	// fileStart is the value of transferred when the current file started.
+	fileStart int64
+	start     time.Time
+	lastPrint time.Time
+	// current transfer
+	phase     string
+	name      string
+	fileBytes int64
+	fileSize  int64
+}
+
+// NewProgress returns a Progress for a batch of files with a total size.
+func NewProgress(totalFiles int, totalBytes int64) *Progress {
+	p := &Progress{
+		out:        os.Stdout,
+		tty:        isTerminal(os.Stdout),
+		totalFiles: totalFiles,
+		totalBytes: totalBytes,
+		start:      time.Now(),
+	}
+	p.interval = 10 * time.Second
+	if p.tty {
+		p.interval = 200 * time.Millisecond
+	}
+	return p
+}
+
+// Begin starts a phase (downloading, uploading) of a file.
+func (p *Progress) Begin(phase, name string, size int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.phase, p.name, p.fileBytes, p.fileSize = phase, name, 0, size
+	p.print(true)
+}
+
+// Reader returns a reader that reports the bytes read from r.
+func (p *Progress) Reader(r io.Reader) io.Reader {
+	if p == nil {
+		return r
+	}
+	return progressReader{r: r, p: p}
+}
+
+// End finishes the phase of the current file.
+func (p *Progress) End() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.print(true)
+	if p.tty {
+		fmt.Fprintln(p.out)
+	}
+}
+
+// FileDone counts a file as done, whether it was copied, skipped or failed.
+func (p *Progress) FileDone(size int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.doneFiles++
+	p.doneBytes += size
+	p.fileStart = p.transferred
+}
+
+func (p *Progress) add(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fileBytes += int64(n)
+	p.transferred += int64(n)
+	p.print(false)
+}
+
+// print writes the status if forced or if the interval has passed. Must hold the lock.
+func (p *Progress) print(force bool) {
+	if !force && time.Since(p.lastPrint) < p.interval {
+		return
+	}
+	p.lastPrint = time.Now()
+	elapsed := time.Since(p.start).Seconds()
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(p.transferred) / elapsed
+	}
+	eta := "?"
+	if rate > 0 {
+		// files still to do are downloaded and uploaded ; skipped files transfer nothing
+		remaining := float64(2*(p.totalBytes-p.doneBytes)-(p.transferred-p.fileStart)) / rate
+		if remaining < 0 {
+			remaining = 0
+		}
+		eta = (time.Duration(remaining) * time.Second).String()
+	}
+	line := fmt.Sprintf("%s %s %s/%s | files %d/%d | %s/%s | %s/s | eta %s",
+		p.phase, p.name,
+		humanSize(p.fileBytes), humanSize(p.fileSize),
+		p.doneFiles, p.totalFiles,
+		humanSize(p.doneBytes), humanSize(p.totalBytes),
+		humanSize(int64(rate)), eta)
+	if p.tty {
+		// clear the rest of the previous line
+		fmt.Fprintf(p.out, "\r%s\033[K", line)
+		return
+	}
+	fmt.Fprintln(p.out, strings.TrimSpace(line))
+}
+
+type progressReader struct {
+	r io.Reader
+	p *Progress
+}
+
+func (r progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	if n > 0 {
+		r.p.add(n)
+	}
+	return n, err
+}
+
+// isTerminal returns whether the file is a terminal (character device).
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestProgressETAIgnoresSkippedFiles(t *testing.T) {
+	out := new(bytes.Buffer)
+	p := NewProgress(2, 200)
+	p.out, p.tty = out, false
+	// the first file is skipped, the second is downloaded and uploaded
+	p.FileDone(100)
+	for _, phase := range []string{"downloading", "uploading"} {
+		p.Begin(phase, "b.jpg", 100)
+		io.Copy(io.Discard, p.Reader(strings.NewReader(strings.Repeat("x", 100))))
+		p.End()
+	}
+	p.FileDone(100)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if last := lines[len(lines)-1]; !strings.HasSuffix(last, "eta 0s") {
+		t.Errorf("got %q, want eta 0s", last)
+	}
+}
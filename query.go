@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const folderMimeType = "application/vnd.google-apps.folder"
+
+// Query builds a search query for Drive files where all clauses must hold.
+// Every value is quoted and escaped.
+// https://developers.google.com/drive/api/guides/ref-search-terms
+type Query struct {
+	clauses []string
+}
+
+func NewQuery() *Query {
+	return new(Query)
+}
+
+// Name requires the exact name.
+func (q *Query) Name(name string) *Query {
+	return q.add("name = " + quoteQuery(name))
+}
+
+// NameContains requires the name to contain the text.
+func (q *Query) NameContains(text string) *Query {
+	return q.add("name contains " + quoteQuery(text))
+}
+
+// InParents requires the file to be in the folder with that id.
+func (q *Query) InParents(id string) *Query {
+	return q.add(quoteQuery(id) + " in parents")
+}
+
+// MimeType requires one of the mime types.
+func (q *Query) MimeType(types ...string) *Query {
+	var list []string
+	for _, each := range types {
+		list = append(list, "mimeType = "+quoteQuery(each))
+	}
+	return q.add(orClauses(list))
+}
+
+// MimeTypeContains requires the mime type to contain the text, e.g. "image/".
+func (q *Query) MimeTypeContains(text string) *Query {
+	return q.add("mimeType contains " + quoteQuery(text))
+}
+
+// NotMimeType requires the file not to have the mime type.
+func (q *Query) NotMimeType(mimeType string) *Query {
+	return q.add("mimeType != " + quoteQuery(mimeType))
+}
+
+// Folder requires the file to be a folder.
+func (q *Query) Folder() *Query {
+	return q.MimeType(folderMimeType)
+}
+
+// Trashed requires the trashed state.
+func (q *Query) Trashed(trashed bool) *Query {
+	return q.add(fmt.Sprintf("trashed = %v", trashed))
+}
+
+// Owner requires the user with the email address to be an owner.
+func (q *Query) Owner(email string) *Query {
+	return q.add(quoteQuery(email) + " in owners")
+}
+
+// NotOwner requires the user with the email address not to be an owner.
+func (q *Query) NotOwner(email string) *Query {
+	return q.add("not " + quoteQuery(email) + " in owners")
+}
+
+// SharedWithMe requires the file to be in the "Shared with me" collection of the user.
+func (q *Query) SharedWithMe() *Query {
+	return q.add("sharedWithMe = true")
+}
+
+// ModifiedAfter requires the modification time to be at or after t.
+func (q *Query) ModifiedAfter(t time.Time) *Query {
+	return q.add("modifiedTime >= " + quoteQuery(t.UTC().Format(time.RFC3339)))
+}
+
+// ModifiedBefore requires the modification time to be before t.
+func (q *Query) ModifiedBefore(t time.Time) *Query {
+	return q.add("modifiedTime < " + quoteQuery(t.UTC().Format(time.RFC3339)))
+}
+
+// Any requires at least one of the queries to hold.
+func (q *Query) Any(queries ...*Query) *Query {
+	var list []string
+	for _, each := range queries {
+		if len(each.clauses) > 0 {
+			list = append(list, "("+each.String()+")")
+		}
+	}
+	return q.add(orClauses(list))
+}
+
+func (q *Query) add(clause string) *Query {
+	if clause != "" {
+		q.clauses = append(q.clauses, clause)
+	}
+	return q
+}
+
+// String returns the query for the Q parameter.
+func (q *Query) String() string {
+	return strings.Join(q.clauses, " and ")
+}
+
+func orClauses(list []string) string {
+	switch len(list) {
+	case 0:
+		return ""
+	case 1:
+		return list[0]
+	}
+	return "(" + strings.Join(list, " or ") + ")"
+}
+
+// quoteQuery returns the value in single quotes, escaping single quotes and backslashes.
+func quoteQuery(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
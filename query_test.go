@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuoteQuery(t *testing.T) {
+	for value, want := range map[string]string{
+		"IMG.jpg":      `'IMG.jpg'`,
+		"Jan's photos": `'Jan\'s photos'`,
+		`a\b`:          `'a\\b'`,
+		`\'`:           `'\\\''`,
+	} {
+		if got := quoteQuery(value); got != want {
+			t.Errorf("quoteQuery(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
+
+func TestQueryString(t *testing.T) {
+	q := NewQuery().InParents("root").Trashed(false).
+		Any(NewQuery().MimeTypeContains("image/"), NewQuery().Name("it's.JPG")).
+		ModifiedAfter(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+	want := `'root' in parents and trashed = false and ((mimeType contains 'image/') or (name = 'it\'s.JPG')) and modifiedTime >= '2019-01-01T00:00:00Z'`
+	if got := q.String(); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if got := NewQuery().MimeType("image/png").Any().String(); got != `mimeType = 'image/png'` {
+		t.Errorf("empty Any adds a clause: %s", got)
+	}
+}
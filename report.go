@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Actions recorded in the transfer report.
+const (
+	Action_Uploaded         = "uploaded"
+	Action_SkippedDuplicate = "skipped-duplicate"
+	Action_Failed           = "failed"
+	Action_SkippedInvalid   = "skipped-invalid"
+	Action_Deleted          = "deleted"
+	Action_Moved            = "moved"             // uploaded, then deleted from Drive
+	Action_DeletedDuplicate = "deleted-duplicate" // already on Google Photos, deleted from Drive
+)
+
+// TransferRecord is one row of the transfer report.
+type TransferRecord struct {
+	DriveID     string `json:"driveId"`
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Action      string `json:"action"`
+	MediaItemID string `json:"mediaItemId,omitempty"`
+	ProductURL  string `json:"productUrl,omitempty"`
+	Bytes       int64  `json:"bytes"`
+	DurationMs  int64  `json:"durationMs"`
+	Error       string `json:"error,omitempty"`
+	started     time.Time
+	err         error
+}
+
+var reportHeader = []string{"drive_id", "name", "path", "action", "media_item_id", "product_url", "bytes", "duration_ms", "error"}
+
+// Report appends a record per Drive file to a CSV or JSON lines file.
+// The format follows from the extension: .csv for CSV, anything else for JSON lines.
+type Report struct {
+	file *os.File
+	csv  *csv.Writer
+}
+
+// OpenReport opens the report file for appending ; a new CSV file starts with a header.
+func OpenReport(path string) (*Report, error) {
+	_, statErr := os.Stat(path)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	r := &Report{file: file}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		r.csv = csv.NewWriter(file)
+		if os.IsNotExist(statErr) {
+			r.csv.Write(reportHeader)
+			r.csv.Flush()
+		}
+	}
+	return r, nil
+}
+
+// Write appends the record ; a nil Report writes nothing.
+func (r *Report) Write(rec TransferRecord) {
+	if r == nil {
+		return
+	}
+	if r.csv != nil {
+		r.csv.Write([]string{
+			rec.DriveID,
+			rec.Name,
+			rec.Path,
+			rec.Action,
+			rec.MediaItemID,
+			rec.ProductURL,
+			strconv.FormatInt(rec.Bytes, 10),
+			strconv.FormatInt(rec.DurationMs, 10),
+			rec.Error,
+		})
+		r.csv.Flush()
+		if err := r.csv.Error(); err != nil {
+			fmt.Println("unable to write report:", err)
+		}
+		return
+	}
+	if err := json.NewEncoder(r.file).Encode(rec); err != nil {
+		fmt.Println("unable to write report:", err)
+	}
+}
+
+func (r *Report) Close() error {
+	if r == nil {
+		return nil
+	}
+	return r.file.Close()
+}
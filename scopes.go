@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/drive/v3"
+)
+
+// Operations that need different authorization scopes.
+const (
+	Operation_Browse = "browse" // ls, ff
+	Operation_Drives = "drives" // listing shared drives
+	Operation_Copy   = "copy"   // cp
+	Operation_Delete = "delete" // rm, mv
+)
+
+// https://developers.google.com/drive/api/guides/api-specific-auth
+var driveScopes = map[string][]string{
+	Operation_Browse: {drive.DriveMetadataReadonlyScope},
+	Operation_Drives: {drive.DriveReadonlyScope}, // drives.list does not accept the metadata scope
+	Operation_Copy:   {drive.DriveReadonlyScope},
+	Operation_Delete: {drive.DriveScope},
+}
+
+// https://developers.google.com/photos/library/guides/authorization
+var photosScopes = map[string][]string{
+	Operation_Browse: {"https://www.googleapis.com/auth/photoslibrary.readonly.appcreateddata"},
+	Operation_Copy: {"https://www.googleapis.com/auth/photoslibrary.readonly.appcreateddata",
+		"https://www.googleapis.com/auth/photoslibrary.appendonly"},
+}
+
+// Authorizer provides the token of a profile and asks the user for more scopes
+// when an operation needs one that was not granted yet (incremental authorization).
+type Authorizer struct {
+	config   *oauth2.Config
+	store    TokenStore
+	authMode string
+	profile  string
+	purpose  string
+
+	mu      sync.Mutex
+	source  oauth2.TokenSource
+	granted []string
+}
+
+func newAuthorizer(config *oauth2.Config, store TokenStore, authMode, profile string) *Authorizer {
+	return &Authorizer{config: config, store: store, authMode: authMode, profile: profile}
+}
+
+// Token implements oauth2.TokenSource.
+// A saved token that Google no longer accepts (revoked or expired) is removed, so the next operation asks to authorize.
+func (a *Authorizer) Token() (*oauth2.Token, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.source == nil {
+		return nil, fmt.Errorf("not authorized")
+	}
+	tok, err := a.source.Token()
+	if rerr, ok := err.(*oauth2.RetrieveError); ok && rerr.ErrorCode == "invalid_grant" {
+		if rmErr := a.store.Remove(); rmErr != nil {
+			fmt.Printf("Unable to remove the invalid token from %s: %v\n", a.store, rmErr)
+		}
+		a.source, a.granted = nil, nil
+	}
+	return tok, err
+}
+
+// Client returns a client that always uses the most recent token.
+func (a *Authorizer) Client() *http.Client {
+	return &http.Client{Transport: &oauth2.Transport{Source: a}}
+}
+
+// Require makes sure that the token has all scopes, starting an authorization flow if not.
+func (a *Authorizer) Require(scopes ...string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.source == nil {
+		tok, err := a.store.Load()
+		if err != nil && !errors.Is(err, errNoToken) {
+			// e.g. a wrong passphrase ; authorizing again would overwrite the saved token
+			return fmt.Errorf("unable to load token from %s: %v", a.store, err)
+		}
+		if err == nil {
+			a.source = a.config.TokenSource(context.Background(), tok)
+			a.granted = grantedScopes(a.source)
+		}
+	}
+	missing := missingScopes(a.granted, scopes)
+	if a.source != nil && len(missing) == 0 {
+		return nil
+	}
+	if a.source != nil {
+		fmt.Println("additional authorization needed for", strings.Join(missing, " "))
+	}
+	fmt.Printf("Authorize %s with the account of your %s\n", a, a.purpose)
+	a.config.Scopes = union(a.granted, scopes)
+	tok := getToken(context.Background(), a.config, a.authMode)
+	if err := saveToken(a.store, tok); err != nil {
+		return err
+	}
+	a.source = a.config.TokenSource(context.Background(), tok)
+	a.granted = a.config.Scopes
+	return nil
+}
+
+// grantedScopes asks Google which scopes the current access token has.
+func grantedScopes(src oauth2.TokenSource) []string {
+	tok, err := src.Token()
+	if err != nil {
+		return nil
+	}
+	resp, err := http.Get("https://oauth2.googleapis.com/tokeninfo?access_token=" + url.QueryEscape(tok.AccessToken))
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	info := struct {
+		Scope string `json:"scope"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil
+	}
+	return strings.Fields(info.Scope)
+}
+
+// missingScopes returns the wanted scopes not in granted. A granted full drive scope covers all drive scopes.
+func missingScopes(granted, wanted []string) (list []string) {
+	for _, each := range wanted {
+		if contains(granted, each) {
+			continue
+		}
+		if strings.HasPrefix(each, drive.DriveScope+".") && contains(granted, drive.DriveScope) {
+			continue
+		}
+		if each == drive.DriveMetadataReadonlyScope && contains(granted, drive.DriveReadonlyScope) {
+			continue
+		}
+		list = append(list, each)
+	}
+	return
+}
+
+func union(a, b []string) (list []string) {
+	for _, each := range append(append([]string{}, a...), b...) {
+		if !contains(list, each) {
+			list = append(list, each)
+		}
+	}
+	return
+}
+
+func contains(list []string, s string) bool {
+	for _, each := range list {
+		if each == s {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize makes sure Drive and Photos have the scopes needed for the operation.
+func (f *Finder) authorize(operation string) bool {
+	var err error
+	if f.driveAuth == f.photosAuth {
+		err = f.driveAuth.Require(union(driveScopes[operation], photosScopes[operation])...)
+	} else if err = f.driveAuth.Require(driveScopes[operation]...); err == nil {
+		err = f.photosAuth.Require(photosScopes[operation]...)
+	}
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	return true
+}
+
+// canDelete returns whether rm and mv are enabled and authorized.
+func (f *Finder) canDelete() bool {
+	if !*allowDelete {
+		fmt.Println("deleting from Google Drive is not enabled, restart with -allow-delete")
+		return false
+	}
+	return f.authorize(Operation_Delete)
+}
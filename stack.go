@@ -25,3 +25,11 @@ func (s *Stack[T]) Size() int {
 func (s *Stack[T]) Top() T {
 	return s.items[len(s.items)-1]
 }
+
+func (s *Stack[T]) Bottom() T {
+	return s.items[0]
+}
+
+func (s *Stack[T]) Clone() *Stack[T] {
+	return &Stack[T]{items: append([]T{}, s.items...)}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"google.golang.org/api/googleapi"
+)
+
+// Status codes of the Google APIs, see https://cloud.google.com/apis/design/errors#handling_errors
+const (
+	Code_OK                 = 0
+	Code_Unknown            = 2
+	Code_InvalidArgument    = 3
+	Code_PermissionDenied   = 7
+	Code_ResourceExhausted  = 8
+	Code_FailedPrecondition = 9
+	Code_Internal           = 13
+	Code_Unavailable        = 14
+)
+
+// Status is the outcome of one item in a batch request.
+type Status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (s Status) OK() bool { return s.Code == Code_OK }
+
+// Temporary returns whether trying again later may succeed.
+func (s Status) Temporary() bool {
+	return s.Code == Code_ResourceExhausted || s.Code == Code_Unavailable || s.Code == Code_Internal
+}
+
+func (s Status) String() string {
+	if s.Message == "" {
+		return fmt.Sprintf("code %d", s.Code)
+	}
+	return fmt.Sprintf("%s (code %d)", s.Message, s.Code)
+}
+
+// UploadError is returned when Google Photos did not create the media item for an uploaded file.
+type UploadError struct {
+	Filename string
+	Status   Status
+}
+
+func (e *UploadError) Error() string {
+	return fmt.Sprintf("Google Photos rejected %s: %v", e.Filename, e.Status)
+}
+
+// HTTPError is returned when a request to Google Photos fails with an HTTP status.
+type HTTPError struct {
+	Op         string
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s failed: %s", e.Op, e.Status)
+}
+
+// isTemporary returns whether trying the same transfer again later may succeed,
+// e.g. after a network error, an exceeded quota or a server error ; not after a permission or format problem.
+func isTemporary(err error) bool {
+	var rejected *UploadError
+	if errors.As(err, &rejected) {
+		return rejected.Status.Temporary()
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return temporaryStatus(httpErr.StatusCode)
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return temporaryStatus(apiErr.Code)
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func temporaryStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
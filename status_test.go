@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestIsTemporary(t *testing.T) {
+	for _, each := range []struct {
+		err  error
+		want bool
+	}{
+		{&UploadError{Status: Status{Code: Code_ResourceExhausted}}, true},
+		{&UploadError{Status: Status{Code: Code_InvalidArgument}}, false},
+		{&HTTPError{Op: "upload", StatusCode: 503}, true},
+		{&HTTPError{Op: "upload", StatusCode: 400}, false},
+		{fmt.Errorf("unable to download file: %w", &googleapi.Error{Code: 403}), false},
+		{fmt.Errorf("unable to download file: %w", &googleapi.Error{Code: 429}), true},
+		{&url.Error{Op: "Get", URL: "https://example.com", Err: timeoutError{}}, true},
+		{errors.New("cannot parse modified time"), false},
+		{nil, false},
+	} {
+		if got := isTemporary(each.err); got != each.want {
+			t.Errorf("%v: got %v want %v", each.err, got, each.want)
+		}
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/peterh/liner"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/oauth2"
+)
+
+const (
+	TokenStore_File      = "file"
+	TokenStore_Encrypted = "encrypted"
+	TokenStore_Keyring   = "keyring"
+)
+
+// TokenStore keeps the OAuth2 token (including the refresh token) between runs.
+type TokenStore interface {
+	// Load returns an error that wraps errNoToken if no token was saved yet.
+	Load() (*oauth2.Token, error)
+	Save(token *oauth2.Token) error
+	// Remove is used when the saved token is no longer valid.
+	Remove() error
+	String() string
+}
+
+// errNoToken means that the store has no token, so the user must authorize.
+var errNoToken = errors.New("no saved token")
+
+// newTokenStore returns the store for a kind, located at path if it is file based or else named by it.
+func newTokenStore(kind, path string) (TokenStore, error) {
+	switch kind {
+	case TokenStore_File, "":
+		return fileTokenStore{path: path}, nil
+	case TokenStore_Encrypted:
+		return encryptedTokenStore{path: path}, nil
+	case TokenStore_Keyring:
+		// path is the account name of the keyring entry
+		return keyringTokenStore{account: path}, nil
+	}
+	return nil, fmt.Errorf("unknown token store: %s", kind)
+}
+
+// configDir returns the directory for credentials, tokens and other state, e.g. ~/.config/drive2photos.
+func configDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "."
+	}
+	return filepath.Join(dir, "drive2photos")
+}
+
+// configFile returns the flag value if set, a file in the working directory if
+// that exists (for backwards compatibility) or else the file in the config directory.
+func configFile(flagValue, name string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if _, err := os.Stat(name); err == nil {
+		return name
+	}
+	return filepath.Join(configDir(), name)
+}
+
+// fileTokenStore stores the token as plain JSON, readable by the user only.
+type fileTokenStore struct {
+	path string
+}
+
+func (s fileTokenStore) Load() (*oauth2.Token, error) {
+	data, err := readTokenFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	tok := &oauth2.Token{}
+	err = json.Unmarshal(data, tok)
+	return tok, err
+}
+
+// readTokenFile returns the content of the token file, or errNoToken if it does not exist.
+func readTokenFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w in %s", errNoToken, path)
+	}
+	return data, err
+}
+
+func (s fileTokenStore) Save(token *oauth2.Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return writePrivateFile(s.path, data)
+}
+
+func (s fileTokenStore) Remove() error  { return os.Remove(s.path) }
+func (s fileTokenStore) String() string { return s.path }
+
+// encryptedTokenStore stores the token encrypted with AES-GCM using a key derived
+// from a passphrase. The passphrase is read from DRIVE2PHOTOS_TOKEN_PASSPHRASE or asked for.
+type encryptedTokenStore struct {
+	path string
+}
+
+const (
+	tokenSaltSize       = 16
+	tokenPassphraseEnv  = "DRIVE2PHOTOS_TOKEN_PASSPHRASE"
+	tokenEncryptedMagic = "d2p1"
+)
+
+func (s encryptedTokenStore) Load() (*oauth2.Token, error) {
+	data, err := readTokenFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte(tokenEncryptedMagic)) {
+		return nil, fmt.Errorf("%s is not an encrypted token file", s.path)
+	}
+	data = data[len(tokenEncryptedMagic):]
+	if len(data) < tokenSaltSize {
+		return nil, errors.New("encrypted token file is too short")
+	}
+	salt, sealed := data[:tokenSaltSize], data[tokenSaltSize:]
+	gcm, err := tokenCipher(salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("encrypted token file is too short")
+	}
+	nonce, sealed := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt token (wrong passphrase?): %v", err)
+	}
+	tok := &oauth2.Token{}
+	err = json.Unmarshal(plain, tok)
+	return tok, err
+}
+
+func (s encryptedTokenStore) Save(token *oauth2.Token) error {
+	plain, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	salt := make([]byte, tokenSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	gcm, err := tokenCipher(salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString(tokenEncryptedMagic)
+	buf.Write(salt)
+	buf.Write(nonce)
+	buf.Write(gcm.Seal(nil, nonce, plain, nil))
+	return writePrivateFile(s.path, buf.Bytes())
+}
+
+func (s encryptedTokenStore) Remove() error  { return os.Remove(s.path) }
+func (s encryptedTokenStore) String() string { return s.path + " (encrypted)" }
+
+var tokenPassphrase string
+
+func tokenCipher(salt []byte) (cipher.AEAD, error) {
+	if tokenPassphrase == "" {
+		tokenPassphrase = os.Getenv(tokenPassphraseEnv)
+	}
+	if tokenPassphrase == "" {
+		passphrase, err := readPassphrase("token passphrase: ")
+		if err != nil {
+			return nil, fmt.Errorf("unable to read passphrase: %v", err)
+		}
+		tokenPassphrase = passphrase
+	}
+	key, err := scrypt.Key([]byte(tokenPassphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// readPassphrase asks for the passphrase without showing it.
+// It is asked before the REPL starts, because the passphrase is kept after loading the token.
+func readPassphrase(prompt string) (string, error) {
+	line := liner.NewLiner()
+	passphrase, err := line.PasswordPrompt(prompt)
+	line.Close()
+	if err == nil || err == liner.ErrPromptAborted {
+		return passphrase, err
+	}
+	// not a terminal, e.g. piped input, so nothing is shown anyway
+	fmt.Print(prompt)
+	_, err = fmt.Scanln(&passphrase)
+	return passphrase, err
+}
+
+// keyringTokenStore stores the token in the Secret Service keyring (GNOME Keyring, KWallet)
+// using the secret-tool command from libsecret.
+type keyringTokenStore struct {
+	account string
+}
+
+const keyringService = "drive2photos"
+
+func (s keyringTokenStore) Load() (*oauth2.Token, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", keyringService, "account", s.account).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) || (err == nil && len(bytes.TrimSpace(out)) == 0) {
+		// secret-tool exits with 1 if there is no such entry
+		return nil, fmt.Errorf("%w in keyring for %s", errNoToken, s.account)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read keyring (is secret-tool from libsecret installed?): %v", err)
+	}
+	tok := &oauth2.Token{}
+	err = json.Unmarshal(out, tok)
+	return tok, err
+}
+
+func (s keyringTokenStore) Save(token *oauth2.Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("secret-tool", "store", "--label=drive2photos OAuth token",
+		"service", keyringService, "account", s.account)
+	cmd.Stdin = bytes.NewReader(data)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("unable to store token in keyring: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (s keyringTokenStore) Remove() error {
+	return exec.Command("secret-tool", "clear", "service", keyringService, "account", s.account).Run()
+}
+
+func (s keyringTokenStore) String() string { return "keyring:" + s.account }
+
+func writePrivateFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// Watcher polls the Drive changes feed and copies new media that appear under the watched folders to Google Photos.
+// https://developers.google.com/drive/api/guides/manage-changes
+type Watcher struct {
+	finder   *Finder
+	folders  map[string]string // folder id -> path
+	interval time.Duration
+	// tokenFile stores the page token of the changes feed between runs.
+	tokenFile string
+	// sinceFile stores the time the first page token was taken ; only files created after it are new.
+	sinceFile string
+	since     time.Time
+	// doneFile stores the ids of new files that were handled, so later changes to them are ignored.
+	doneFile string
+	done     map[string]bool
+	// failedFile stores the ids of files that could not be copied for a temporary reason, to retry them, between runs.
+	failedFile string
+	failed     map[string]bool
+	// parents caches the folders (name and parent ids) to find out whether a file is under a watched folder.
+	parents map[string]*drive.File
+}
+
+func newWatcher(f *Finder, paths []string, interval time.Duration) (*Watcher, error) {
+	w := &Watcher{
+		finder:     f,
+		folders:    map[string]string{},
+		interval:   interval,
+		tokenFile:  filepath.Join(configDir(), "watch-"+*driveProfile+".token"),
+		sinceFile:  filepath.Join(configDir(), "watch-"+*driveProfile+".since"),
+		doneFile:   filepath.Join(configDir(), "watch-"+*driveProfile+".done"),
+		failedFile: filepath.Join(configDir(), "watch-"+*driveProfile+".failed"),
+		parents:    map[string]*drive.File{},
+	}
+	for _, each := range paths {
+		stack, err := f.resolve(each)
+		if err != nil {
+			return nil, err
+		}
+		id := stack.Top().Id
+		if id == "root" {
+			// parents of files have the real id of My Drive, not its alias
+			root, err := f.drive.File(id)
+			if err != nil {
+				return nil, err
+			}
+			id = root.Id
+		}
+		w.folders[id] = Path(stack)
+	}
+	w.done = loadIDs(w.doneFile)
+	w.failed = loadIDs(w.failedFile)
+	if len(w.folders) == 0 {
+		return nil, fmt.Errorf("no folders to watch")
+	}
+	return w, nil
+}
+
+// Run polls until the process receives an interrupt or terminate signal.
+// The current file is finished before stopping.
+func (w *Watcher) Run() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	pageToken, err := w.loadPageToken()
+	if err != nil {
+		log.Fatalf("Unable to get start page token: %v", err)
+	}
+	for _, each := range w.folders {
+		log.Println("watching", each)
+	}
+	for {
+		pageToken = w.poll(ctx, pageToken)
+		select {
+		case <-ctx.Done():
+			log.Println("stopped watching")
+			return
+		case <-time.After(w.interval):
+		}
+	}
+}
+
+// poll retries the files that failed before, processes all pending changes and returns the page token for the next poll.
+func (w *Watcher) poll(ctx context.Context, pageToken string) string {
+	w.retry(ctx)
+	for pageToken != "" {
+		if ctx.Err() != nil {
+			return pageToken
+		}
+		changes, err := w.finder.drive.Changes(pageToken)
+		if err != nil {
+			log.Printf("Unable to retrieve changes: %v", err)
+			return pageToken
+		}
+		for _, each := range changes.Changes {
+			if ctx.Err() != nil {
+				// do not save the token ; the remaining changes are processed on the next run
+				return pageToken
+			}
+			w.handle(each)
+		}
+		if changes.NewStartPageToken != "" {
+			w.savePageToken(changes.NewStartPageToken)
+			return changes.NewStartPageToken
+		}
+		pageToken = changes.NextPageToken
+		w.savePageToken(pageToken)
+	}
+	return pageToken
+}
+
+func (w *Watcher) handle(change *drive.Change) {
+	file := change.File
+	if change.Removed || file == nil || file.Trashed || !isMedia(file) {
+		return
+	}
+	// renames and other updates of existing files are changes too
+	if w.done[file.Id] || w.failed[file.Id] || !w.isNew(file) {
+		return
+	}
+	folder, ok := w.watchedFolder(file.Parents)
+	if !ok {
+		return
+	}
+	log.Println("new media", file.Name, "in", folder)
+	w.copy(file, folder)
+}
+
+// isNew returns whether the file was created after watching started.
+func (w *Watcher) isNew(file *drive.File) bool {
+	created, err := time.Parse(time.RFC3339, file.CreatedTime)
+	return err == nil && !created.Before(w.since)
+}
+
+// copy copies the file and keeps its id to retry on the next poll if that failed for a reason that may pass.
+func (w *Watcher) copy(file *drive.File, folder string) {
+	if w.finder.folderPaths == nil {
+		w.finder.folderPaths = map[string]string{}
+	}
+	w.finder.folderPaths[file.Id] = folder
+	rec := w.finder.newRecord(file)
+	ok := w.finder.copyFile(file, TransferOptions{}, &rec)
+	w.finder.record(rec)
+	if !ok && isTemporary(rec.err) {
+		w.failed[file.Id] = true
+	} else {
+		delete(w.failed, file.Id)
+		w.done[file.Id] = true
+	}
+	saveIDs(w.failedFile, w.failed)
+	saveIDs(w.doneFile, w.done)
+}
+
+// retry copies the files that failed before.
+func (w *Watcher) retry(ctx context.Context) {
+	for id := range w.failed {
+		if ctx.Err() != nil {
+			return
+		}
+		file, err := w.finder.drive.File(id)
+		if err != nil || file.Trashed {
+			log.Printf("not retrying %s: %v", id, err)
+			delete(w.failed, id)
+			saveIDs(w.failedFile, w.failed)
+			continue
+		}
+		folder, ok := w.watchedFolder(file.Parents)
+		if !ok {
+			// moved out of the watched folders
+			delete(w.failed, id)
+			saveIDs(w.failedFile, w.failed)
+			continue
+		}
+		log.Println("retrying", file.Name)
+		w.copy(file, folder)
+	}
+}
+
+// watchedFolder returns the Drive path of the folder with one of the parent ids,
+// if that folder is a watched folder or below one.
+func (w *Watcher) watchedFolder(parents []string) (string, bool) {
+	return w.folderPath(parents, map[string]bool{})
+}
+
+func (w *Watcher) folderPath(parents []string, seen map[string]bool) (string, bool) {
+	for _, id := range parents {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if path, ok := w.folders[id]; ok {
+			return path, true
+		}
+		folder, ok := w.parents[id]
+		if !ok {
+			folder, _ = w.finder.drive.Folder(id)
+			w.parents[id] = folder
+		}
+		if folder == nil {
+			continue
+		}
+		if path, ok := w.folderPath(folder.Parents, seen); ok {
+			return strings.TrimSuffix(path, "/") + "/" + folder.Name, true
+		}
+	}
+	return "", false
+}
+
+func (w *Watcher) loadPageToken() (string, error) {
+	data, err := os.ReadFile(w.tokenFile)
+	if err == nil && len(strings.TrimSpace(string(data))) > 0 && w.loadSince() {
+		return strings.TrimSpace(string(data)), nil
+	}
+	// first run: only changes from now on
+	w.since = time.Now()
+	if err := writePrivateFile(w.sinceFile, []byte(w.since.Format(time.RFC3339))); err != nil {
+		return "", err
+	}
+	token, err := w.finder.drive.StartPageToken()
+	if err != nil {
+		return "", err
+	}
+	w.savePageToken(token)
+	return token, nil
+}
+
+func (w *Watcher) loadSince() bool {
+	data, err := os.ReadFile(w.sinceFile)
+	if err != nil {
+		return false
+	}
+	w.since, err = time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	return err == nil
+}
+
+func (w *Watcher) savePageToken(token string) {
+	if err := writePrivateFile(w.tokenFile, []byte(token)); err != nil {
+		log.Printf("Unable to save page token: %v", err)
+	}
+}
+
+// loadIDs returns the set of file ids in the file, one per line.
+func loadIDs(path string) map[string]bool {
+	ids := map[string]bool{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ids
+	}
+	for _, each := range strings.Fields(string(data)) {
+		ids[each] = true
+	}
+	return ids
+}
+
+func saveIDs(path string, ids map[string]bool) {
+	b := new(strings.Builder)
+	for each := range ids {
+		fmt.Fprintln(b, each)
+	}
+	if err := writePrivateFile(path, []byte(b.String())); err != nil {
+		log.Printf("Unable to save %s: %v", path, err)
+	}
+}
+
+// isMedia returns whether the file is a photo or video.
+func isMedia(file *drive.File) bool {
+	return strings.HasPrefix(file.MimeType, "image/") || strings.HasPrefix(file.MimeType, "video/")
+}